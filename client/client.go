@@ -0,0 +1,128 @@
+// Package client is a typed Go client for the uptime monitor's HTTP API.
+//
+//	c := client.New("https://uptime.example.com", os.Getenv("UPTIME_TOKEN"))
+//	targets, err := c.ListTargets(ctx)
+//
+// The types and methods in client_gen.go are generated from the server's
+// OpenAPI document (/api/openapi.json); run go generate after changing a
+// route or one of its types.
+package client
+
+//go:generate go run ./internal/gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the API at BaseURL with an API token.
+type Client struct {
+	// BaseURL is the server's address without the /api suffix.
+	BaseURL string
+	// Token is sent as a bearer token. Create one with POST /api/tokens
+	// or in the web UI.
+	Token string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token}
+}
+
+// Error is returned for responses with a non-2xx status. Fields is only set
+// when the server rejected individual request fields.
+type Error struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("uptime: %d %s", e.StatusCode, e.Message)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// send performs a request and returns the response if its status is 2xx.
+// Errors are decoded from either an APIError document or a text body.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	u := c.BaseURL + "/api" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(b))}
+	var doc APIError
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(b, &doc) == nil {
+		apiErr.Message, apiErr.Fields = doc.Error, doc.Fields
+	}
+	return nil, apiErr
+}
+
+// do performs a request and decodes a JSON response into out, unless out
+// is nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stream performs a request whose response is not JSON and returns its
+// body, which the caller must close.
+func (c *Client) stream(ctx context.Context, method, path string, query url.Values) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, method, path, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,1290 @@
+// Code generated by go run ./internal/gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIError is the API's APIError object.
+type APIError struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// APIToken is the API's APIToken object.
+type APIToken struct {
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	ID         int64      `json:"id"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Scope      string     `json:"scope"`
+	UserID     int64      `json:"userId"`
+	Username   string     `json:"username"`
+}
+
+// AuditEntry is the API's AuditEntry object.
+type AuditEntry struct {
+	Actor    string          `json:"actor"`
+	After    json.RawMessage `json:"after,omitempty"`
+	At       time.Time       `json:"at"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	Changes  json.RawMessage `json:"changes,omitempty"`
+	ID       int64           `json:"id"`
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Resource string          `json:"resource,omitempty"`
+	Role     string          `json:"role,omitempty"`
+	SourceIP string          `json:"sourceIp,omitempty"`
+	Status   int             `json:"status"`
+	TokenID  int64           `json:"tokenId,omitempty"`
+}
+
+// AuthProviders is the API's AuthProviders object.
+type AuthProviders struct {
+	OIDC bool `json:"oidc"`
+}
+
+// AuthResponse is the API's AuthResponse object.
+type AuthResponse struct {
+	CSRFToken string    `json:"csrfToken,omitempty"`
+	User      Principal `json:"user"`
+}
+
+// BackupInfo is the API's BackupInfo object.
+type BackupInfo struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+}
+
+// BulkPauseRequest is the API's BulkPauseRequest object.
+type BulkPauseRequest struct {
+	IDs      []int      `json:"ids"`
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
+	ResumeIn string     `json:"resumeIn,omitempty"`
+}
+
+// BulkResumeRequest is the API's BulkResumeRequest object.
+type BulkResumeRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// CheckEvent is the API's CheckEvent object.
+type CheckEvent struct {
+	CheckedAt  time.Time `json:"checkedAt"`
+	Duration   int64     `json:"duration"`
+	ID         int64     `json:"id,omitempty"`
+	Message    string    `json:"message"`
+	Status     bool      `json:"status"`
+	Target     string    `json:"target"`
+	TargetID   int       `json:"targetId"`
+	TargetName string    `json:"targetName"`
+	Type       string    `json:"type"`
+}
+
+// CheckPage is the API's CheckPage object.
+type CheckPage struct {
+	Checks     []CheckResponse `json:"checks"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+// CheckResponse is the API's CheckResponse object.
+type CheckResponse struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Duration  int64     `json:"duration"`
+	ID        int64     `json:"id,omitempty"`
+	Message   string    `json:"message"`
+	Status    bool      `json:"status"`
+	Target    string    `json:"target"`
+	Type      string    `json:"type"`
+}
+
+// ExportCheck is the API's ExportCheck object.
+type ExportCheck struct {
+	CheckedAt  time.Time `json:"checkedAt"`
+	Duration   int64     `json:"duration"`
+	ID         int64     `json:"id,omitempty"`
+	Message    string    `json:"message"`
+	Status     bool      `json:"status"`
+	Target     string    `json:"target"`
+	TargetName string    `json:"targetName"`
+	Type       string    `json:"type"`
+}
+
+// ExportDocument is the API's ExportDocument object.
+type ExportDocument struct {
+	Checks        []ExportCheck        `json:"checks,omitempty"`
+	ExportedAt    time.Time            `json:"exportedAt"`
+	Notifications *ExportNotifications `json:"notifications,omitempty"`
+	Settings      *Settings            `json:"settings,omitempty"`
+	Targets       []ExportTarget       `json:"targets"`
+	Version       int                  `json:"version"`
+}
+
+// ExportNotifications is the API's ExportNotifications object.
+type ExportNotifications struct {
+	Telegram *TelegramConfig `json:"telegram,omitempty"`
+}
+
+// ExportTarget is the API's ExportTarget object.
+type ExportTarget struct {
+	Name       string `json:"name"`
+	Password   string `json:"password,omitempty"`
+	Subscribed bool   `json:"subscribed"`
+	Type       string `json:"type"`
+	URL        string `json:"url"`
+	Username   string `json:"username,omitempty"`
+}
+
+// FieldError is the API's FieldError object.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FleetStats is the API's FleetStats object.
+type FleetStats struct {
+	Overall Stats   `json:"overall"`
+	Targets []Stats `json:"targets"`
+}
+
+// Health is the API's Health object.
+type Health struct {
+	CheckWriter WriteStatus `json:"checkWriter"`
+}
+
+// ImportResult is the API's ImportResult object.
+type ImportResult struct {
+	Checks    int           `json:"checks"`
+	Created   []string      `json:"created"`
+	Deleted   []string      `json:"deleted"`
+	DryRun    bool          `json:"dryRun"`
+	Mode      string        `json:"mode"`
+	Settings  *SettingsDiff `json:"settings,omitempty"`
+	Telegram  bool          `json:"telegram,omitempty"`
+	Unchanged []string      `json:"unchanged"`
+	Updated   []string      `json:"updated"`
+}
+
+// Incident is the API's Incident object.
+type Incident struct {
+	EndedAt      *time.Time `json:"endedAt,omitempty"`
+	FailedChecks int        `json:"failedChecks"`
+	FirstError   string     `json:"firstError"`
+	ID           int64      `json:"id"`
+	LastError    string     `json:"lastError"`
+	Notes        string     `json:"notes"`
+	RootCause    string     `json:"rootCause"`
+	StartedAt    time.Time  `json:"startedAt"`
+	TargetID     int        `json:"targetId"`
+	TargetName   string     `json:"targetName"`
+}
+
+// IncidentPatch is the API's IncidentPatch object.
+type IncidentPatch struct {
+	Notes     *string `json:"notes,omitempty"`
+	RootCause *string `json:"rootCause,omitempty"`
+}
+
+// LoginRequest is the API's LoginRequest object.
+type LoginRequest struct {
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+// PasswordRequest is the API's PasswordRequest object.
+type PasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// Pause is the API's Pause object.
+type Pause struct {
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
+	Since    time.Time  `json:"since"`
+}
+
+// PauseRequest is the API's PauseRequest object.
+type PauseRequest struct {
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
+	ResumeIn string     `json:"resumeIn,omitempty"`
+}
+
+// Principal is the API's Principal object.
+type Principal struct {
+	Role     string `json:"role"`
+	Scope    string `json:"scope,omitempty"`
+	TokenID  int64  `json:"tokenId,omitempty"`
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+}
+
+// Report is the API's Report object.
+type Report struct {
+	Format    string     `json:"format"`
+	ID        int64      `json:"id"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+	Name      string     `json:"name"`
+	Period    string     `json:"period"`
+	TargetIDs []int      `json:"targetIds"`
+}
+
+// Result is the API's Result object.
+type Result struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Duration  int64     `json:"duration"`
+	Message   string    `json:"message"`
+	Status    bool      `json:"status"`
+	Target    string    `json:"target"`
+	Type      string    `json:"type"`
+}
+
+// SLO is the API's SLO object.
+type SLO struct {
+	Alerting           bool    `json:"alerting"`
+	ID                 int64   `json:"id"`
+	LatencyThresholdMs int     `json:"latencyThresholdMs,omitempty"`
+	Name               string  `json:"name"`
+	Objective          float64 `json:"objective"`
+	TargetIDs          []int   `json:"targetIds"`
+	WindowDays         int     `json:"windowDays"`
+}
+
+// SLOStatus is the API's SLOStatus object.
+type SLOStatus struct {
+	Alerting             bool               `json:"alerting"`
+	BurnRates            map[string]float64 `json:"burnRates"`
+	Checks               int                `json:"checks"`
+	Compliance           *float64           `json:"compliance"`
+	ErrorBudgetRemaining *float64           `json:"errorBudgetRemaining"`
+	From                 time.Time          `json:"from"`
+	GoodChecks           int                `json:"goodChecks"`
+	ID                   int64              `json:"id"`
+	LatencyThresholdMs   int                `json:"latencyThresholdMs,omitempty"`
+	Name                 string             `json:"name"`
+	Objective            float64            `json:"objective"`
+	TargetIDs            []int              `json:"targetIds"`
+	To                   time.Time          `json:"to"`
+	WindowDays           int                `json:"windowDays"`
+}
+
+// SeriesBucket is the API's SeriesBucket object.
+type SeriesBucket struct {
+	AvgMs    *float64  `json:"avgMs"`
+	Count    int       `json:"count"`
+	Failures int       `json:"failures"`
+	P50Ms    *int64    `json:"p50Ms"`
+	P95Ms    *int64    `json:"p95Ms"`
+	P99Ms    *int64    `json:"p99Ms"`
+	Start    time.Time `json:"start"`
+	Uptime   *float64  `json:"uptime"`
+}
+
+// Settings is the API's Settings object.
+type Settings struct {
+	Frequency      int `json:"frequency"`
+	TimeframeHours int `json:"timeframeHours"`
+}
+
+// SettingsDiff is the API's SettingsDiff object.
+type SettingsDiff struct {
+	From Settings `json:"from"`
+	To   Settings `json:"to"`
+}
+
+// StateEvent is the API's StateEvent object.
+type StateEvent struct {
+	State      TargetState `json:"state"`
+	TargetID   int         `json:"targetId"`
+	TargetName string      `json:"targetName"`
+}
+
+// Stats is the API's Stats object.
+type Stats struct {
+	AvgMs           *float64  `json:"avgMs"`
+	Checks          int       `json:"checks"`
+	DowntimeMinutes float64   `json:"downtimeMinutes"`
+	FailedChecks    int       `json:"failedChecks"`
+	From            time.Time `json:"from"`
+	Incidents       int       `json:"incidents"`
+	MTBFSeconds     *float64  `json:"mtbfSeconds"`
+	MTTRSeconds     *float64  `json:"mttrSeconds"`
+	P50Ms           *int64    `json:"p50Ms"`
+	P95Ms           *int64    `json:"p95Ms"`
+	P99Ms           *int64    `json:"p99Ms"`
+	PausedMinutes   float64   `json:"pausedMinutes"`
+	Recovered       int       `json:"recovered"`
+	TargetID        int       `json:"targetId,omitempty"`
+	TargetName      string    `json:"targetName,omitempty"`
+	To              time.Time `json:"to"`
+	UptimePercent   *float64  `json:"uptimePercent"`
+}
+
+// TargetInfo is the API's TargetInfo object.
+type TargetInfo struct {
+	ID          int          `json:"id"`
+	Managed     bool         `json:"managed"`
+	Name        string       `json:"name"`
+	PasswordRef string       `json:"passwordRef,omitempty"`
+	Paused      *Pause       `json:"paused,omitempty"`
+	State       *TargetState `json:"state,omitempty"`
+	Subscribed  bool         `json:"subscribed"`
+	Type        string       `json:"type"`
+	URL         string       `json:"url"`
+	Username    string       `json:"username,omitempty"`
+}
+
+// TargetPatch is the API's TargetPatch object.
+type TargetPatch struct {
+	Name       *string `json:"name,omitempty"`
+	Password   *string `json:"password,omitempty"`
+	Subscribed *bool   `json:"subscribed,omitempty"`
+	Type       *string `json:"type,omitempty"`
+	URL        *string `json:"url,omitempty"`
+	Username   *string `json:"username,omitempty"`
+}
+
+// TargetRequest is the API's TargetRequest object.
+type TargetRequest struct {
+	ID       int    `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+}
+
+// TargetState is the API's TargetState object.
+type TargetState struct {
+	ConsecutiveFailures  int        `json:"consecutiveFailures"`
+	ConsecutiveSuccesses int        `json:"consecutiveSuccesses"`
+	LastCheckedAt        time.Time  `json:"lastCheckedAt"`
+	LastMessage          string     `json:"lastMessage"`
+	LastNotifiedAt       *time.Time `json:"lastNotifiedAt,omitempty"`
+	Since                time.Time  `json:"since"`
+	Up                   bool       `json:"up"`
+}
+
+// TelegramConfig is the API's TelegramConfig object.
+type TelegramConfig struct {
+	BotToken string `json:"botToken"`
+	ChatID   string `json:"chatId"`
+}
+
+// TokenRequest is the API's TokenRequest object.
+type TokenRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiresIn string     `json:"expiresIn,omitempty"`
+	Name      string     `json:"name"`
+	Scope     string     `json:"scope,omitempty"`
+}
+
+// TokenResponse is the API's TokenResponse object.
+type TokenResponse struct {
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	ID         int64      `json:"id"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Scope      string     `json:"scope"`
+	Token      string     `json:"token"`
+	UserID     int64      `json:"userId"`
+	Username   string     `json:"username"`
+}
+
+// User is the API's User object.
+type User struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        int64     `json:"id"`
+	Role      string    `json:"role"`
+	Username  string    `json:"username"`
+}
+
+// UserPatch is the API's UserPatch object.
+type UserPatch struct {
+	Password *string `json:"password,omitempty"`
+	Role     *string `json:"role,omitempty"`
+}
+
+// UserRequest is the API's UserRequest object.
+type UserRequest struct {
+	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
+	Username string `json:"username"`
+}
+
+// WriteStatus is the API's WriteStatus object.
+type WriteStatus struct {
+	DroppedChecks int64      `json:"droppedChecks"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// ListAuditParams holds the optional query parameters of ListAudit.
+type ListAuditParams struct {
+	// Username, or "config file".
+	Actor string
+	// Resource such as target:3; a trailing colon matches every resource of the kind.
+	Resource string
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+	// Maximum number of results.
+	Limit int
+	// Only entries older than this entry ID.
+	Before int
+}
+
+func (p *ListAuditParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Actor != "" {
+		q.Set("actor", p.Actor)
+	}
+	if p.Resource != "" {
+		q.Set("resource", p.Resource)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Before != 0 {
+		q.Set("before", strconv.Itoa(p.Before))
+	}
+	return q
+}
+
+// ListAudit calls GET /api/audit: query the audit log, newest first.
+// Requires the admin role.
+func (c *Client) ListAudit(ctx context.Context, params *ListAuditParams) ([]AuditEntry, error) {
+	var out []AuditEntry
+	if err := c.do(ctx, "GET", "/audit", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Login calls POST /api/auth/login: start a session with a username and password.
+func (c *Client) Login(ctx context.Context, body LoginRequest) (*AuthResponse, error) {
+	var out AuthResponse
+	if err := c.do(ctx, "POST", "/auth/login", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Logout calls POST /api/auth/logout: end the current session.
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, "POST", "/auth/logout", nil, nil, nil)
+}
+
+// GetMe calls GET /api/auth/me: the logged-in user and the session's CSRF token.
+func (c *Client) GetMe(ctx context.Context) (*AuthResponse, error) {
+	var out AuthResponse
+	if err := c.do(ctx, "GET", "/auth/me", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ChangePassword calls POST /api/auth/password: change the own password and end all sessions.
+func (c *Client) ChangePassword(ctx context.Context, body PasswordRequest) error {
+	return c.do(ctx, "POST", "/auth/password", nil, body, nil)
+}
+
+// GetAuthProviders calls GET /api/auth/providers: the login methods offered besides username and password.
+func (c *Client) GetAuthProviders(ctx context.Context) (*AuthProviders, error) {
+	var out AuthProviders
+	if err := c.do(ctx, "GET", "/auth/providers", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBackups calls GET /api/backups: list database backups.
+// Requires the admin role.
+func (c *Client) ListBackups(ctx context.Context) ([]BackupInfo, error) {
+	var out []BackupInfo
+	if err := c.do(ctx, "GET", "/backups", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateBackup calls POST /api/backups: back up the database now.
+// Requires the admin role.
+func (c *Client) CreateBackup(ctx context.Context) (*BackupInfo, error) {
+	var out BackupInfo
+	if err := c.do(ctx, "POST", "/backups", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListChecks calls GET /api/checks: checks of every target within the configured timeframe.
+func (c *Client) ListChecks(ctx context.Context) ([]CheckResponse, error) {
+	var out []CheckResponse
+	if err := c.do(ctx, "GET", "/checks", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExportChecksParams holds the optional query parameters of ExportChecks.
+type ExportChecksParams struct {
+	// Output format, csv by default.
+	Format string
+	// Target ID.
+	Target int
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+}
+
+func (p *ExportChecksParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	if p.Target != 0 {
+		q.Set("target", strconv.Itoa(p.Target))
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	return q
+}
+
+// ExportChecks calls GET /api/checks/export: stream raw check rows, oldest first.
+func (c *Client) ExportChecks(ctx context.Context, params *ExportChecksParams) (io.ReadCloser, error) {
+	return c.stream(ctx, "GET", "/checks/export", params.values())
+}
+
+// StreamEventsParams holds the optional query parameters of StreamEvents.
+type StreamEventsParams struct {
+	// Comma-separated target IDs; every target by default.
+	Targets string
+}
+
+func (p *StreamEventsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Targets != "" {
+		q.Set("targets", p.Targets)
+	}
+	return q
+}
+
+// StreamEvents calls GET /api/events: stream new check results (CheckEvent) and status transitions (StateEvent) as Server-Sent Events.
+func (c *Client) StreamEvents(ctx context.Context, params *StreamEventsParams) (io.ReadCloser, error) {
+	return c.stream(ctx, "GET", "/events", params.values())
+}
+
+// ExportParams holds the optional query parameters of Export.
+type ExportParams struct {
+	// Include passwords.
+	Credentials string
+	// Include check history.
+	History bool
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+}
+
+func (p *ExportParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Credentials != "" {
+		q.Set("credentials", p.Credentials)
+	}
+	if p.History {
+		q.Set("history", "true")
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	return q
+}
+
+// Export calls GET /api/export: export settings, targets and optionally history.
+// Requires the admin role.
+func (c *Client) Export(ctx context.Context, params *ExportParams) (*ExportDocument, error) {
+	var out ExportDocument
+	if err := c.do(ctx, "GET", "/export", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHealth calls GET /api/health: checks that were accepted but could not be stored.
+func (c *Client) GetHealth(ctx context.Context) (*Health, error) {
+	var out Health
+	if err := c.do(ctx, "GET", "/health", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportParams holds the optional query parameters of Import.
+type ImportParams struct {
+	// merge by default.
+	Mode string
+	// Only report the changes.
+	DryRun bool
+}
+
+func (p *ImportParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Mode != "" {
+		q.Set("mode", p.Mode)
+	}
+	if p.DryRun {
+		q.Set("dryRun", "true")
+	}
+	return q
+}
+
+// Import calls POST /api/import: apply an export document.
+// Requires the admin role.
+func (c *Client) Import(ctx context.Context, body ExportDocument, params *ImportParams) (*ImportResult, error) {
+	var out ImportResult
+	if err := c.do(ctx, "POST", "/import", params.values(), body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListIncidentsParams holds the optional query parameters of ListIncidents.
+type ListIncidentsParams struct {
+	// Target ID.
+	Target int
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+	// Only open incidents.
+	Open bool
+	// Maximum number of results.
+	Limit int
+}
+
+func (p *ListIncidentsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Target != 0 {
+		q.Set("target", strconv.Itoa(p.Target))
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	if p.Open {
+		q.Set("open", "true")
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// ListIncidents calls GET /api/incidents: list incidents, newest first.
+func (c *Client) ListIncidents(ctx context.Context, params *ListIncidentsParams) ([]Incident, error) {
+	var out []Incident
+	if err := c.do(ctx, "GET", "/incidents", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetIncident calls GET /api/incidents/{id}: get an incident.
+func (c *Client) GetIncident(ctx context.Context, id int64) (*Incident, error) {
+	var out Incident
+	if err := c.do(ctx, "GET", fmt.Sprintf("/incidents/%d", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AnnotateIncident calls PATCH /api/incidents/{id}: set an incident's notes and root cause.
+// Requires the operator role.
+func (c *Client) AnnotateIncident(ctx context.Context, id int64, body IncidentPatch) (*Incident, error) {
+	var out Incident
+	if err := c.do(ctx, "PATCH", fmt.Sprintf("/incidents/%d", id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetOpenAPI calls GET /api/openapi.json: this OpenAPI document.
+func (c *Client) GetOpenAPI(ctx context.Context) (map[string]json.RawMessage, error) {
+	var out map[string]json.RawMessage
+	if err := c.do(ctx, "GET", "/openapi.json", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListReports calls GET /api/reports: list scheduled reports.
+func (c *Client) ListReports(ctx context.Context) ([]Report, error) {
+	var out []Report
+	if err := c.do(ctx, "GET", "/reports", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateReport calls POST /api/reports: create a scheduled report.
+// Requires the admin role.
+func (c *Client) CreateReport(ctx context.Context, body Report) (*Report, error) {
+	var out Report
+	if err := c.do(ctx, "POST", "/reports", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetReport calls GET /api/reports/{id}: get a scheduled report.
+func (c *Client) GetReport(ctx context.Context, id int64) (*Report, error) {
+	var out Report
+	if err := c.do(ctx, "GET", fmt.Sprintf("/reports/%d", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateReport calls PUT /api/reports/{id}: replace a scheduled report.
+// Requires the admin role.
+func (c *Client) UpdateReport(ctx context.Context, id int64, body Report) (*Report, error) {
+	var out Report
+	if err := c.do(ctx, "PUT", fmt.Sprintf("/reports/%d", id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteReport calls DELETE /api/reports/{id}: delete a scheduled report.
+// Requires the admin role.
+func (c *Client) DeleteReport(ctx context.Context, id int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/reports/%d", id), nil, nil, nil)
+}
+
+// SendReport calls POST /api/reports/{id}/send: send a report for the last complete period now.
+// Requires the operator role.
+func (c *Client) SendReport(ctx context.Context, id int64) error {
+	return c.do(ctx, "POST", fmt.Sprintf("/reports/%d/send", id), nil, nil, nil)
+}
+
+// GetSettings calls GET /api/settings: check frequency and chart timeframe.
+func (c *Client) GetSettings(ctx context.Context) (*Settings, error) {
+	var out Settings
+	if err := c.do(ctx, "GET", "/settings", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSettings calls POST /api/settings: change the settings.
+// Requires the admin role.
+func (c *Client) UpdateSettings(ctx context.Context, body Settings) error {
+	return c.do(ctx, "POST", "/settings", nil, body, nil)
+}
+
+// ListSLOs calls GET /api/slos: list SLOs with their status.
+func (c *Client) ListSLOs(ctx context.Context) ([]SLOStatus, error) {
+	var out []SLOStatus
+	if err := c.do(ctx, "GET", "/slos", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateSLO calls POST /api/slos: create an SLO.
+// Requires the admin role.
+func (c *Client) CreateSLO(ctx context.Context, body SLO) (*SLOStatus, error) {
+	var out SLOStatus
+	if err := c.do(ctx, "POST", "/slos", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSLO calls GET /api/slos/{id}: get an SLO with its status.
+func (c *Client) GetSLO(ctx context.Context, id int64) (*SLOStatus, error) {
+	var out SLOStatus
+	if err := c.do(ctx, "GET", fmt.Sprintf("/slos/%d", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSLO calls PUT /api/slos/{id}: replace an SLO.
+// Requires the admin role.
+func (c *Client) UpdateSLO(ctx context.Context, id int64, body SLO) (*SLOStatus, error) {
+	var out SLOStatus
+	if err := c.do(ctx, "PUT", fmt.Sprintf("/slos/%d", id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSLO calls DELETE /api/slos/{id}: delete an SLO.
+// Requires the admin role.
+func (c *Client) DeleteSLO(ctx context.Context, id int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/slos/%d", id), nil, nil, nil)
+}
+
+// GetStatsParams holds the optional query parameters of GetStats.
+type GetStatsParams struct {
+	// Window ending now, such as 24h or 30d; 24h by default.
+	Window string
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+}
+
+func (p *GetStatsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Window != "" {
+		q.Set("window", p.Window)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	return q
+}
+
+// GetStats calls GET /api/stats: availability statistics of every target and the fleet.
+func (c *Client) GetStats(ctx context.Context, params *GetStatsParams) (*FleetStats, error) {
+	var out FleetStats
+	if err := c.do(ctx, "GET", "/stats", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTargets calls GET /api/targets: list targets with their current state.
+func (c *Client) ListTargets(ctx context.Context) ([]TargetInfo, error) {
+	var out []TargetInfo
+	if err := c.do(ctx, "GET", "/targets", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateTarget calls POST /api/targets: create a target.
+// Requires the admin role.
+func (c *Client) CreateTarget(ctx context.Context, body TargetRequest) (*TargetInfo, error) {
+	var out TargetInfo
+	if err := c.do(ctx, "POST", "/targets", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReplaceTarget calls PUT /api/targets: replace the target with the body's id.
+// Requires the admin role.
+func (c *Client) ReplaceTarget(ctx context.Context, body TargetRequest) (*TargetInfo, error) {
+	var out TargetInfo
+	if err := c.do(ctx, "PUT", "/targets", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteTargetByQueryParams holds the optional query parameters of DeleteTargetByQuery.
+type DeleteTargetByQueryParams struct {
+	// Target ID.
+	ID int
+}
+
+func (p *DeleteTargetByQueryParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.ID != 0 {
+		q.Set("id", strconv.Itoa(p.ID))
+	}
+	return q
+}
+
+// DeleteTargetByQuery calls DELETE /api/targets: delete the target given by id.
+// Requires the admin role.
+func (c *Client) DeleteTargetByQuery(ctx context.Context, params *DeleteTargetByQueryParams) error {
+	return c.do(ctx, "DELETE", "/targets", params.values(), nil, nil)
+}
+
+// ClearChecksParams holds the optional query parameters of ClearChecks.
+type ClearChecksParams struct {
+	// Target URL.
+	Target string
+}
+
+func (p *ClearChecksParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Target != "" {
+		q.Set("target", p.Target)
+	}
+	return q
+}
+
+// ClearChecks calls POST /api/targets/clear: delete the check history of a target.
+// Requires the admin role.
+func (c *Client) ClearChecks(ctx context.Context, params *ClearChecksParams) error {
+	return c.do(ctx, "POST", "/targets/clear", params.values(), nil, nil)
+}
+
+// PauseTargets calls POST /api/targets/pause: pause several targets.
+// Requires the operator role.
+func (c *Client) PauseTargets(ctx context.Context, body BulkPauseRequest) ([]TargetInfo, error) {
+	var out []TargetInfo
+	if err := c.do(ctx, "POST", "/targets/pause", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResumeTargets calls POST /api/targets/resume: resume several paused targets.
+// Requires the operator role.
+func (c *Client) ResumeTargets(ctx context.Context, body BulkResumeRequest) ([]TargetInfo, error) {
+	var out []TargetInfo
+	if err := c.do(ctx, "POST", "/targets/resume", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubscribeTargetParams holds the optional query parameters of SubscribeTarget.
+type SubscribeTargetParams struct {
+	// Target ID.
+	ID int
+}
+
+func (p *SubscribeTargetParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.ID != 0 {
+		q.Set("id", strconv.Itoa(p.ID))
+	}
+	return q
+}
+
+// SubscribeTarget calls POST /api/targets/subscribe: send notifications for a target.
+// Requires the operator role.
+func (c *Client) SubscribeTarget(ctx context.Context, params *SubscribeTargetParams) error {
+	return c.do(ctx, "POST", "/targets/subscribe", params.values(), nil, nil)
+}
+
+// UnsubscribeTargetParams holds the optional query parameters of UnsubscribeTarget.
+type UnsubscribeTargetParams struct {
+	// Target ID.
+	ID int
+}
+
+func (p *UnsubscribeTargetParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.ID != 0 {
+		q.Set("id", strconv.Itoa(p.ID))
+	}
+	return q
+}
+
+// UnsubscribeTarget calls POST /api/targets/unsubscribe: stop notifications for a target.
+// Requires the operator role.
+func (c *Client) UnsubscribeTarget(ctx context.Context, params *UnsubscribeTargetParams) error {
+	return c.do(ctx, "POST", "/targets/unsubscribe", params.values(), nil, nil)
+}
+
+// GetTarget calls GET /api/targets/{id}: get a target.
+func (c *Client) GetTarget(ctx context.Context, id int64) (*TargetInfo, error) {
+	var out TargetInfo
+	if err := c.do(ctx, "GET", fmt.Sprintf("/targets/%d", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateTarget calls PATCH /api/targets/{id}: change the given fields of a target.
+// Requires the admin role.
+func (c *Client) UpdateTarget(ctx context.Context, id int64, body TargetPatch) (*TargetInfo, error) {
+	var out TargetInfo
+	if err := c.do(ctx, "PATCH", fmt.Sprintf("/targets/%d", id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteTarget calls DELETE /api/targets/{id}: delete a target.
+// Requires the admin role.
+func (c *Client) DeleteTarget(ctx context.Context, id int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/targets/%d", id), nil, nil, nil)
+}
+
+// CheckTarget calls POST /api/targets/{id}/check: check a target now and return the result; duration is in nanoseconds.
+// Requires the operator role.
+func (c *Client) CheckTarget(ctx context.Context, id int64) (*Result, error) {
+	var out Result
+	if err := c.do(ctx, "POST", fmt.Sprintf("/targets/%d/check", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTargetChecksParams holds the optional query parameters of ListTargetChecks.
+type ListTargetChecksParams struct {
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+	// Only successful or failed checks.
+	Status string
+	// Page size, 100 by default and at most 1000.
+	Limit int
+	// nextCursor of the previous page.
+	Cursor string
+}
+
+func (p *ListTargetChecksParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// ListTargetChecks calls GET /api/targets/{id}/checks: A target's check history, newest first.
+func (c *Client) ListTargetChecks(ctx context.Context, id int64, params *ListTargetChecksParams) (*CheckPage, error) {
+	var out CheckPage
+	if err := c.do(ctx, "GET", fmt.Sprintf("/targets/%d/checks", id), params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PauseTarget calls POST /api/targets/{id}/pause: stop checking a target, optionally until a given time.
+// Requires the operator role.
+func (c *Client) PauseTarget(ctx context.Context, id int64, body PauseRequest) (*TargetInfo, error) {
+	var out TargetInfo
+	if err := c.do(ctx, "POST", fmt.Sprintf("/targets/%d/pause", id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResumeTarget calls POST /api/targets/{id}/resume: resume checking a paused target.
+// Requires the operator role.
+func (c *Client) ResumeTarget(ctx context.Context, id int64) (*TargetInfo, error) {
+	var out TargetInfo
+	if err := c.do(ctx, "POST", fmt.Sprintf("/targets/%d/resume", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTargetSeriesParams holds the optional query parameters of GetTargetSeries.
+type GetTargetSeriesParams struct {
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+	// Bucket size, a duration or seconds.
+	Step string
+}
+
+func (p *GetTargetSeriesParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	if p.Step != "" {
+		q.Set("step", p.Step)
+	}
+	return q
+}
+
+// GetTargetSeries calls GET /api/targets/{id}/series: aggregated chart buckets of a target.
+func (c *Client) GetTargetSeries(ctx context.Context, id int64, params *GetTargetSeriesParams) ([]SeriesBucket, error) {
+	var out []SeriesBucket
+	if err := c.do(ctx, "GET", fmt.Sprintf("/targets/%d/series", id), params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetTargetStatsParams holds the optional query parameters of GetTargetStats.
+type GetTargetStatsParams struct {
+	// Window ending now, such as 24h or 30d; 24h by default.
+	Window string
+	// Start of the window.
+	From time.Time
+	// End of the window.
+	To time.Time
+}
+
+func (p *GetTargetStatsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Window != "" {
+		q.Set("window", p.Window)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339Nano))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339Nano))
+	}
+	return q
+}
+
+// GetTargetStats calls GET /api/targets/{id}/stats: availability statistics of a target.
+func (c *Client) GetTargetStats(ctx context.Context, id int64, params *GetTargetStatsParams) (*Stats, error) {
+	var out Stats
+	if err := c.do(ctx, "GET", fmt.Sprintf("/targets/%d/stats", id), params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestTelegram calls POST /api/test-telegram: send a test notification.
+// Requires the operator role.
+func (c *Client) TestTelegram(ctx context.Context) error {
+	return c.do(ctx, "POST", "/test-telegram", nil, nil, nil)
+}
+
+// ListTokens calls GET /api/tokens: list own API tokens, or every token for admins.
+func (c *Client) ListTokens(ctx context.Context) ([]APIToken, error) {
+	var out []APIToken
+	if err := c.do(ctx, "GET", "/tokens", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateToken calls POST /api/tokens: create an API token; the secret is only returned here.
+func (c *Client) CreateToken(ctx context.Context, body TokenRequest) (*TokenResponse, error) {
+	var out TokenResponse
+	if err := c.do(ctx, "POST", "/tokens", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeToken calls DELETE /api/tokens/{id}: revoke an API token.
+func (c *Client) RevokeToken(ctx context.Context, id int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/tokens/%d", id), nil, nil, nil)
+}
+
+// ListUsers calls GET /api/users: list users.
+// Requires the admin role.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var out []User
+	if err := c.do(ctx, "GET", "/users", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateUser calls POST /api/users: create a local user.
+// Requires the admin role.
+func (c *Client) CreateUser(ctx context.Context, body UserRequest) (*User, error) {
+	var out User
+	if err := c.do(ctx, "POST", "/users", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateUser calls PATCH /api/users/{id}: change a user's role or password.
+// Requires the admin role.
+func (c *Client) UpdateUser(ctx context.Context, id int64, body UserPatch) (*User, error) {
+	var out User
+	if err := c.do(ctx, "PATCH", fmt.Sprintf("/users/%d", id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteUser calls DELETE /api/users/{id}: delete a user.
+// Requires the admin role.
+func (c *Client) DeleteUser(ctx context.Context, id int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/users/%d", id), nil, nil, nil)
+}
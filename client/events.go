@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Event is one Server-Sent Event from /api/events. Name is "check" (Data is
+// a CheckEvent), "state" (a StateEvent) or "reset", which means events were
+// missed and the caller should reload what it displays.
+type Event struct {
+	ID   string
+	Name string
+	Data json.RawMessage
+}
+
+// EventStream reads events from /api/events.
+type EventStream struct {
+	// LastID is the ID of the latest event read, including events that
+	// were filtered out. Pass it to Events to resume after a disconnect.
+	LastID string
+
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// Events opens the event stream. With a lastID from a previous stream it
+// starts with the events missed since then; otherwise only new events are
+// sent.
+func (c *Client) Events(ctx context.Context, lastID string, params *StreamEventsParams) (*EventStream, error) {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if lastID != "" {
+		header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := c.send(ctx, "GET", "/events", params.values(), header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewEventStream(resp.Body), nil
+}
+
+// NewEventStream reads events from r, such as the body returned by
+// StreamEvents.
+func NewEventStream(r io.ReadCloser) *EventStream {
+	return &EventStream{body: r, scanner: bufio.NewScanner(r)}
+}
+
+// Next blocks until the next event and returns it. It returns io.EOF when
+// the server ends the stream.
+func (s *EventStream) Next() (Event, error) {
+	var ev Event
+	var data []string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if len(data) == 0 {
+				// Comments and bare IDs dispatch nothing.
+				ev = Event{}
+				continue
+			}
+			ev.Data = json.RawMessage(strings.Join(data, "\n"))
+			return ev, nil
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Name = value
+		case "id":
+			ev.ID, s.LastID = value, value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// Close ends the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
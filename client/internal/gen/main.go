@@ -0,0 +1,336 @@
+// Command gen writes client_gen.go from the server's OpenAPI document. It is
+// run by go generate in the client package:
+//
+//	go generate ./client
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"uptime/server"
+)
+
+type document struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	ID          string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	AllOf                []*schema          `json:"allOf"`
+	GoName               string             `json:"x-go-name"`
+}
+
+// methodOrder sorts the operations of a path.
+var methodOrder = map[string]int{"get": 0, "post": 1, "put": 2, "patch": 3, "delete": 4}
+
+// generator collects the output and the imports it needs.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func main() {
+	src, err := generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("client_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the source of client_gen.go.
+func generate() ([]byte, error) {
+	b, err := json.Marshal(server.OpenAPI())
+	if err != nil {
+		return nil, err
+	}
+	var doc document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	g := &generator{imports: map[string]bool{}}
+	var names []string
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.structType(name, doc.Components.Schemas[name])
+	}
+
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var methods []string
+		for method := range doc.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Slice(methods, func(i, j int) bool { return methodOrder[methods[i]] < methodOrder[methods[j]] })
+		for _, method := range methods {
+			g.method(strings.ToUpper(method), path, doc.Paths[path][method])
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by go run ./internal/gen; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format: %v\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) structType(name string, s *schema) {
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	var props []string
+	for prop := range s.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	g.printf("\n// %s is the API's %s object.\ntype %s struct {\n", name, name, name)
+	for _, prop := range props {
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		g.printf("%s %s `json:%q`\n", s.Properties[prop].GoName, g.goType(s.Properties[prop]), tag)
+	}
+	g.printf("}\n")
+}
+
+// goType is the Go type encoding/json maps to and from s.
+func (g *generator) goType(s *schema) string {
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	}
+	if len(s.AllOf) == 1 {
+		t := g.goType(s.AllOf[0])
+		if s.Nullable {
+			t = "*" + t
+		}
+		return t
+	}
+	var t string
+	switch s.Type {
+	case "":
+		g.imports["encoding/json"] = true
+		return "json.RawMessage"
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "object":
+		var elem schema
+		if err := json.Unmarshal(s.AdditionalProperties, &elem); err != nil || len(s.Properties) > 0 {
+			log.Fatalf("gen: inline objects are not supported")
+		}
+		return "map[string]" + g.goType(&elem)
+	case "string":
+		t = "string"
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			t = "time.Time"
+		}
+	case "integer":
+		t = "int"
+		if s.Format == "int64" {
+			t = "int64"
+		}
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	default:
+		log.Fatalf("gen: unsupported type %q", s.Type)
+	}
+	if s.Nullable {
+		t = "*" + t
+	}
+	return t
+}
+
+// method writes the client method of an operation, and the struct of its
+// query parameters if it has any.
+func (g *generator) method(method, path string, op operation) {
+	var status string
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") || strings.HasPrefix(code, "3") {
+			status = code
+		}
+	}
+	if strings.HasPrefix(status, "3") {
+		// Redirects are for browsers.
+		return
+	}
+	name := exported(op.ID)
+	g.imports["context"] = true
+
+	args := []string{"ctx context.Context"}
+	pathExpr := fmt.Sprintf("%q", path)
+	var query []parameter
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			args = append(args, p.Name+" int64")
+			g.imports["fmt"] = true
+			pathExpr = fmt.Sprintf("fmt.Sprintf(%q, %s)", strings.ReplaceAll(path, "{"+p.Name+"}", "%d"), p.Name)
+		case "query":
+			query = append(query, p)
+		}
+	}
+	bodyArg := "nil"
+	if op.RequestBody != nil {
+		args = append(args, "body "+g.goType(op.RequestBody.Content["application/json"].Schema))
+		bodyArg = "body"
+	}
+	queryArg := "nil"
+	if len(query) > 0 {
+		g.queryParams(name+"Params", query)
+		args = append(args, "params *"+name+"Params")
+		queryArg = "params.values()"
+	}
+
+	summary := op.Summary
+	if r := []rune(summary); len(r) > 1 && unicode.IsLower(r[1]) {
+		summary = string(unicode.ToLower(r[0])) + string(r[1:])
+	}
+	g.printf("\n// %s calls %s /api%s: %s.", name, method, path, summary)
+	if op.Description != "" {
+		g.printf("\n// %s", op.Description)
+	}
+	g.printf("\nfunc (c *Client) %s(%s) ", name, strings.Join(args, ", "))
+
+	content := op.Responses[status].Content
+	switch {
+	case len(content) == 0:
+		g.printf("error {\nreturn c.do(ctx, %q, %s, %s, %s, nil)\n}\n", method, pathExpr, queryArg, bodyArg)
+	case content["application/json"].Schema != nil:
+		t := g.goType(content["application/json"].Schema)
+		if !strings.HasPrefix(t, "[]") && !strings.HasPrefix(t, "map[") {
+			t = "*" + t
+		}
+		g.printf("(%s, error) {\nvar out %s\n", t, strings.TrimPrefix(t, "*"))
+		g.printf("if err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\n", method, pathExpr, queryArg, bodyArg)
+		if strings.HasPrefix(t, "*") {
+			g.printf("return &out, nil\n}\n")
+		} else {
+			g.printf("return out, nil\n}\n")
+		}
+	default:
+		g.imports["io"] = true
+		g.printf("(io.ReadCloser, error) {\nreturn c.stream(ctx, %q, %s, %s)\n}\n", method, pathExpr, queryArg)
+	}
+}
+
+// queryParams writes the struct of an operation's query parameters. Zero
+// fields are left out of the query.
+func (g *generator) queryParams(name string, params []parameter) {
+	g.imports["net/url"] = true
+	g.printf("\n// %s holds the optional query parameters of %s.\ntype %s struct {\n", name, strings.TrimSuffix(name, "Params"), name)
+	for _, p := range params {
+		if p.Description != "" {
+			g.printf("// %s\n", p.Description)
+		}
+		g.printf("%s %s\n", exported(p.Name), paramType(p.Schema))
+	}
+	g.printf("}\n\nfunc (p *%s) values() url.Values {\nq := url.Values{}\nif p == nil {\nreturn q\n}\n", name)
+	for _, p := range params {
+		field := "p." + exported(p.Name)
+		switch paramType(p.Schema) {
+		case "int":
+			g.imports["strconv"] = true
+			g.printf("if %s != 0 {\nq.Set(%q, strconv.Itoa(%s))\n}\n", field, p.Name, field)
+		case "bool":
+			g.printf("if %s {\nq.Set(%q, \"true\")\n}\n", field, p.Name)
+		case "time.Time":
+			g.imports["time"] = true
+			g.printf("if !%s.IsZero() {\nq.Set(%q, %s.Format(time.RFC3339Nano))\n}\n", field, p.Name, field)
+		default:
+			g.printf("if %s != \"\" {\nq.Set(%q, %s)\n}\n", field, p.Name, field)
+		}
+	}
+	g.printf("return q\n}\n")
+}
+
+func paramType(s *schema) string {
+	switch {
+	case s.Type == "integer":
+		return "int"
+	case s.Type == "boolean":
+		return "bool"
+	case s.Format == "date-time":
+		return "time.Time"
+	}
+	return "string"
+}
+
+// exported turns an operation or parameter name into an exported Go name,
+// keeping the initialism ID upper case.
+func exported(name string) string {
+	if name == "id" {
+		return "ID"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
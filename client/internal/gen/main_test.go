@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestClientIsGenerated fails when client_gen.go differs from what
+// go generate ./client would write, e.g. after a route or schema change.
+func TestClientIsGenerated(t *testing.T) {
+	want, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client/client_gen.go is out of date; run go generate ./client")
+	}
+}
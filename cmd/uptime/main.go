@@ -1,13 +1,34 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	"uptime/server"
+	"uptime/storage"
 )
 
 func main() {
-	if err := server.Run(); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: uptime restore <backup-file>")
+			os.Exit(2)
+		}
+		if err := storage.Restore(os.Args[2], storage.Path()); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("restored %s to %s", os.Args[2], storage.Path())
+		return
+	}
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "declarative YAML or JSON config file")
+	seedExamples := flag.Bool("seed-examples", os.Getenv("SEED_EXAMPLES") == "true", "add example targets when creating a new database")
+	flag.Parse()
+
+	opts := server.Options{ConfigFile: *configFile, SeedExamples: *seedExamples}
+	if err := server.Run(opts); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -13,6 +13,7 @@ import {
   LineChart,
   Sun,
   Moon,
+  LogOut,
 } from 'lucide-react';
 import { Button } from '@/components/ui/button';
 import {
@@ -24,8 +25,8 @@ import {
 } from '@/components/ui/card';
 import { Badge } from '@/components/ui/badge';
 
-import { useChecks, useSettings, useTargets } from '@/hooks/useApi';
-import { TargetInfo } from '@/types';
+import { useAuth, useChecks, useSettings, useTargets } from '@/hooks/useApi';
+import { TargetInfo, User } from '@/types';
 import {
   formatDuration,
   getStatusBgColor,
@@ -41,8 +42,30 @@ import { UptimeChart } from '@/components/uptime-chart';
 import { Overview } from './components/overview';
 import { ServiceDropdownMenu } from './components/service-menu';
 import { ModeToggle } from './components/theme-toggle';
+import { Login } from './components/login';
 
 function App() {
+  const { user, providers, loading, error, login, logout } = useAuth();
+
+  if (loading) {
+    return (
+      <div className={`min-h-screen flex items-center justify-center `}>
+        <div className="animate-spin rounded-full h-32 w-32 border-b-2 border-gray-900 mx-auto"></div>
+      </div>
+    );
+  }
+  if (!user) {
+    return <Login error={error} sso={providers.oidc} onLogin={login} />;
+  }
+  return <Dashboard user={user} onLogout={logout} />;
+}
+
+interface DashboardProps {
+  user: User;
+  onLogout: () => Promise<void>;
+}
+
+function Dashboard({ user, onLogout }: DashboardProps) {
   const {
     settings,
     loading: settingsLoading,
@@ -259,6 +282,10 @@ function App() {
               <SettingsIcon className="h-4 w-4 mr-2" />
               Settings
             </Button>
+            <Button variant="outline" onClick={onLogout}>
+              <LogOut className="h-4 w-4 mr-2" />
+              {user.username}
+            </Button>
           </div>
         </div>
 
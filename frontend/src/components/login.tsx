@@ -0,0 +1,89 @@
+import { useState } from 'react';
+import { Button } from '@/components/ui/button';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+
+interface LoginProps {
+  error: string | null;
+  // sso shows a button for single sign-on, which the server handles with
+  // a redirect to the identity provider and back.
+  sso: boolean;
+  onLogin: (username: string, password: string) => Promise<void>;
+}
+
+export function Login({ error, sso, onLogin }: LoginProps) {
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
+  const [submitting, setSubmitting] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setSubmitting(true);
+    await onLogin(username, password);
+    setSubmitting(false);
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center p-6">
+      <Card className="w-full max-w-sm">
+        <CardHeader className="items-center text-center">
+          <img
+            src={'./logo.svg'}
+            alt="Uptime Monitor Logo"
+            className="h-16 w-16"
+          />
+          <CardTitle>Uptime Monitor</CardTitle>
+          <CardDescription>Sign in to continue</CardDescription>
+        </CardHeader>
+        <CardContent>
+          <form onSubmit={handleSubmit} className="space-y-4">
+            <div className="space-y-2">
+              <Label htmlFor="username">Username</Label>
+              <Input
+                id="username"
+                autoComplete="username"
+                value={username}
+                onChange={(e) => setUsername(e.target.value)}
+                required
+              />
+            </div>
+            <div className="space-y-2">
+              <Label htmlFor="password">Password</Label>
+              <Input
+                id="password"
+                type="password"
+                autoComplete="current-password"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+                required
+              />
+            </div>
+            {error && <p className="text-sm text-red-500">{error}</p>}
+            <Button type="submit" className="w-full" disabled={submitting}>
+              Sign in
+            </Button>
+          </form>
+          {sso && (
+            <div className="mt-4 space-y-4">
+              <p className="text-center text-sm text-muted-foreground">or</p>
+              <Button
+                variant="outline"
+                className="w-full"
+                onClick={() => window.location.assign('/api/auth/oidc/login')}
+              >
+                Sign in with single sign-on
+              </Button>
+            </div>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
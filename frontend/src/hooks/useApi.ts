@@ -1,5 +1,113 @@
 import { useState, useEffect, useCallback } from 'react';
-import { TargetInfo, CheckResult, Settings } from '@/types';
+import {
+  TargetInfo,
+  CheckResult,
+  Settings,
+  User,
+  AuthResponse,
+  AuthProviders,
+} from '@/types';
+
+// The session's CSRF token, from the last login or /api/auth/me. The server
+// also sets it in the uptime_csrf cookie, which covers a reload before
+// /api/auth/me has answered.
+let csrfToken: string | null = null;
+
+function csrfFromCookie() {
+  const match = document.cookie.match(/(?:^|;\s*)uptime_csrf=([^;]*)/);
+  return match ? decodeURIComponent(match[1]) : null;
+}
+
+// apiFetch is fetch for the API: it sends the CSRF token with every request
+// that is not a read, as the server requires for session logins, and reports
+// an expired session to useAuth.
+export async function apiFetch(input: string, init: RequestInit = {}) {
+  const method = (init.method ?? 'GET').toUpperCase();
+  const headers = new Headers(init.headers);
+  if (!['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+    const token = csrfToken ?? csrfFromCookie();
+    if (token) headers.set('X-CSRF-Token', token);
+  }
+  const response = await fetch(input, { ...init, headers });
+  if (response.status === 401 && !input.startsWith('/api/auth/')) {
+    window.dispatchEvent(new Event('uptime:unauthorized'));
+  }
+  return response;
+}
+
+export function useAuth() {
+  const [user, setUser] = useState<User | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+  const [providers, setProviders] = useState<AuthProviders>({ oidc: false });
+
+  const accept = (data: AuthResponse) => {
+    csrfToken = data.csrfToken ?? null;
+    setUser(data.user);
+    setError(null);
+  };
+
+  const fetchMe = useCallback(async () => {
+    try {
+      const response = await apiFetch('/api/auth/me');
+      if (response.status === 401) {
+        csrfToken = null;
+        setUser(null);
+        return;
+      }
+      if (!response.ok) {
+        throw new Error('Failed to fetch the current user');
+      }
+      accept(await response.json());
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Unknown error');
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  const login = async (username: string, password: string) => {
+    try {
+      const response = await apiFetch('/api/auth/login', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ username, password }),
+      });
+      if (!response.ok) {
+        throw new Error(
+          response.status === 401
+            ? 'Invalid username or password'
+            : 'Failed to log in'
+        );
+      }
+      accept(await response.json());
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Unknown error');
+    }
+  };
+
+  const logout = async () => {
+    await apiFetch('/api/auth/logout', { method: 'POST' });
+    csrfToken = null;
+    setUser(null);
+  };
+
+  useEffect(() => {
+    fetchMe();
+    apiFetch('/api/auth/providers')
+      .then((response) => (response.ok ? response.json() : { oidc: false }))
+      .then(setProviders)
+      .catch(() => setProviders({ oidc: false }));
+    const expired = () => {
+      csrfToken = null;
+      setUser(null);
+    };
+    window.addEventListener('uptime:unauthorized', expired);
+    return () => window.removeEventListener('uptime:unauthorized', expired);
+  }, [fetchMe]);
+
+  return { user, providers, loading, error, login, logout };
+}
 
 export function useChecks(timeframeHours: number, frequency: number) {
   const [checks, setChecks] = useState<CheckResult[]>([]);
@@ -11,7 +119,7 @@ export function useChecks(timeframeHours: number, frequency: number) {
       if (!isBackground) {
         setLoading(true);
       }
-      const response = await fetch('/api/checks');
+      const response = await apiFetch('/api/checks');
       if (!response.ok) {
         throw new Error('Failed to fetch checks');
       }
@@ -31,10 +139,40 @@ export function useChecks(timeframeHours: number, frequency: number) {
   useEffect(() => {
     fetchChecks(false);
 
-    const refreshInterval = Math.max(frequency * 1000, 1000);
-    const interval = setInterval(() => fetchChecks(true), refreshInterval);
+    // New checks arrive over /api/events; the browser reconnects with
+    // Last-Event-ID by itself. Polling is only the fallback for when the
+    // stream is refused.
+    let interval: ReturnType<typeof setInterval> | undefined;
+    const poll = () => {
+      const refreshInterval = Math.max(frequency * 1000, 1000);
+      interval = setInterval(() => fetchChecks(true), refreshInterval);
+    };
+    if (typeof EventSource === 'undefined') {
+      poll();
+      return () => clearInterval(interval);
+    }
+
+    const source = new EventSource('/api/events');
+    source.addEventListener('check', (e) => {
+      const check: CheckResult = JSON.parse((e as MessageEvent).data);
+      const cutoff = Date.now() - timeframeHours * 60 * 60 * 1000;
+      setChecks((prev) => [
+        ...prev.filter((c) => new Date(c.checkedAt).getTime() >= cutoff),
+        check,
+      ]);
+    });
+    // Events were missed while disconnected.
+    source.addEventListener('reset', () => fetchChecks(true));
+    source.onerror = () => {
+      if (source.readyState === EventSource.CLOSED && interval === undefined) {
+        poll();
+      }
+    };
 
-    return () => clearInterval(interval);
+    return () => {
+      source.close();
+      clearInterval(interval);
+    };
   }, [timeframeHours, frequency, fetchChecks]);
 
   return {
@@ -57,7 +195,7 @@ export function useSettings() {
   const fetchSettings = async () => {
     try {
       setLoading(true);
-      const response = await fetch('/api/settings');
+      const response = await apiFetch('/api/settings');
       if (!response.ok) {
         throw new Error('Failed to fetch settings');
       }
@@ -73,7 +211,7 @@ export function useSettings() {
 
   const updateSettings = async (newSettings: Settings) => {
     try {
-      const response = await fetch('/api/settings', {
+      const response = await apiFetch('/api/settings', {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -108,7 +246,7 @@ export function useTargets(
   const fetchTargets = useCallback(async () => {
     try {
       setLoading(true);
-      const response = await fetch('/api/targets');
+      const response = await apiFetch('/api/targets');
       if (!response.ok) {
         throw new Error('Failed to fetch targets');
       }
@@ -140,7 +278,7 @@ export function useTargets(
 
   const addTarget = async (target: Omit<TargetInfo, 'id'>) => {
     try {
-      const response = await fetch('/api/targets', {
+      const response = await apiFetch('/api/targets', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(target),
@@ -156,7 +294,7 @@ export function useTargets(
 
   const updateTarget = async (target: TargetInfo) => {
     try {
-      const response = await fetch(`/api/targets`, {
+      const response = await apiFetch(`/api/targets`, {
         method: 'PUT',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(target),
@@ -172,7 +310,7 @@ export function useTargets(
 
   const deleteTarget = async (id: number) => {
     try {
-      const response = await fetch(`/api/targets?id=${id}`, {
+      const response = await apiFetch(`/api/targets?id=${id}`, {
         method: 'DELETE',
       });
       if (!response.ok) {
@@ -186,7 +324,7 @@ export function useTargets(
 
   const clearChecks = async (targetUrl: string) => {
     try {
-      await fetch(
+      await apiFetch(
         `/api/targets/clear?target=${encodeURIComponent(targetUrl)}`,
         {
           method: 'POST',
@@ -225,7 +363,7 @@ export function useTargets(
 
   const subscribeTarget = async (id: number) => {
     try {
-      await fetch(`/api/targets/subscribe?id=${id}`, { method: 'POST' });
+      await apiFetch(`/api/targets/subscribe?id=${id}`, { method: 'POST' });
       setTargets((prevTargets) =>
         prevTargets.map((t) => (t.id === id ? { ...t, subscribed: true } : t))
       );
@@ -236,7 +374,7 @@ export function useTargets(
 
   const unsubscribeTarget = async (id: number) => {
     try {
-      await fetch(`/api/targets/unsubscribe?id=${id}`, { method: 'POST' });
+      await apiFetch(`/api/targets/unsubscribe?id=${id}`, { method: 'POST' });
       setTargets((prevTargets) =>
         prevTargets.map((t) => (t.id === id ? { ...t, subscribed: false } : t))
       );
@@ -246,7 +384,7 @@ export function useTargets(
   };
 
   const testTelegram = async () => {
-    await fetch('/api/test-telegram', { method: 'POST' });
+    await apiFetch('/api/test-telegram', { method: 'POST' });
   };
 
   useEffect(() => {
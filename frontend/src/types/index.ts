@@ -37,6 +37,22 @@ export interface TargetInfo {
   username?: string;
   password?: string;
   subscribed?: boolean;
+  paused?: { since: string; resumeAt?: string };
+}
+
+export interface User {
+  userId: number;
+  username: string;
+  role: "viewer" | "operator" | "admin";
+}
+
+export interface AuthResponse {
+  user: User;
+  csrfToken?: string;
+}
+
+export interface AuthProviders {
+  oidc: boolean;
 }
 
 export interface ApiResponse<T> {
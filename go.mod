@@ -5,15 +5,20 @@ go 1.24
 toolchain go1.24.3
 
 require (
+	github.com/coreos/go-oidc/v3 v3.14.1
+	github.com/g-h-miles/httpmux v0.1.1
 	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.28
 	github.com/redis/go-redis/v9 v9.10.0
+	golang.org/x/crypto v0.38.0
+	golang.org/x/oauth2 v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/g-h-miles/httpmux v0.1.1 // indirect
 	github.com/g-h-miles/std-middleware v0.1.0-experimental.1 // indirect
+	github.com/go-jose/go-jose/v4 v4.0.5 // indirect
 	github.com/rs/cors v1.11.1 // indirect
 )
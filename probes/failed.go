@@ -0,0 +1,15 @@
+package probes
+
+import "time"
+
+// Failed target always reports the error that prevented building a real probe,
+// e.g. an unresolvable secret reference
+type Failed struct {
+	Addr string
+	Kind string
+	Err  error
+}
+
+func (f Failed) Check() Result {
+	return Result{Target: f.Addr, Type: f.Kind, Status: false, CheckedAt: time.Now(), Message: f.Err.Error()}
+}
@@ -2,7 +2,7 @@ package probes
 
 import (
 	"database/sql"
-	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -16,16 +16,11 @@ type Postgres struct {
 	DB   string
 }
 
-func (p Postgres) Check() Result {
-	start := time.Now()
-
-	// Parse existing query parameters from Addr
-	addr := p.Addr
-	existingParams := ""
-	if parts := strings.SplitN(p.Addr, "?", 2); len(parts) == 2 {
-		addr = parts[0]
-		existingParams = parts[1]
-	}
+// dsn builds the connection URL for p. Addr is host:port with an optional
+// /database and ?parameters; the credentials are escaped so any character
+// may appear in them.
+func (p Postgres) dsn() string {
+	addr, existingParams, _ := strings.Cut(p.Addr, "?")
 
 	// Build query parameters
 	params := []string{"connect_timeout=10"}
@@ -40,49 +35,46 @@ func (p Postgres) Check() Result {
 		params = append(params, "sslmode=disable")
 	}
 
-	queryString := strings.Join(params, "&")
-
-	var dsn string
+	host, dbName, _ := strings.Cut(addr, "/")
+	if dbName == "" {
+		dbName = p.DB
+	}
+	if dbName == "" {
+		dbName = "postgres"
+	}
+	u := url.URL{Scheme: "postgres", Host: host, Path: "/" + dbName, RawQuery: strings.Join(params, "&")}
 	if p.User != "" && p.Pass != "" {
-		// Check if addr already contains a database path (has a slash after the host:port)
-		if strings.Contains(addr, "/") && !strings.HasSuffix(addr, "/") {
-			// Addr already contains database name
-			dsn = fmt.Sprintf("postgres://%s:%s@%s?%s", p.User, p.Pass, addr, queryString)
-		} else {
-			// Need to append database name
-			dbName := p.DB
-			if dbName == "" {
-				dbName = "postgres"
-			}
-			// Remove trailing slash if present
-			addr = strings.TrimSuffix(addr, "/")
-			dsn = fmt.Sprintf("postgres://%s:%s@%s/%s?%s", p.User, p.Pass, addr, dbName, queryString)
-		}
-	} else {
-		dbName := p.DB
-		if dbName == "" {
-			dbName = "postgres"
+		u.User = url.UserPassword(p.User, p.Pass)
+	}
+	return u.String()
+}
+
+// failure describes err for the check result. The driver may quote the
+// connection URL, so the password is masked in case it is a resolved secret.
+func (p Postgres) failure(err error) string {
+	msg := err.Error()
+	if p.Pass != "" {
+		for _, s := range []string{p.Pass, url.QueryEscape(p.Pass), url.PathEscape(p.Pass), url.UserPassword("", p.Pass).String()[1:]} {
+			msg = strings.ReplaceAll(msg, s, "xxxxx")
 		}
-		dsn = fmt.Sprintf("postgres://%s/%s?%s", addr, dbName, queryString)
 	}
+	return msg
+}
 
-	// Debug logging
-	// log.Printf("Postgres probe: connecting to %s (target: %s)", dsn, p.Addr)
+func (p Postgres) Check() Result {
+	start := time.Now()
 
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", p.dsn())
 	if err != nil {
-		// log.Printf("Postgres probe: sql.Open failed for %s: %v", p.Addr, err)
-		return Result{Target: p.Addr, Type: "postgres", Status: false, Duration: time.Since(start), CheckedAt: time.Now(), Message: err.Error()}
+		return Result{Target: p.Addr, Type: "postgres", Status: false, Duration: time.Since(start), CheckedAt: time.Now(), Message: p.failure(err)}
 	}
 	defer db.Close()
 
 	err = db.Ping()
 	duration := time.Since(start)
 	if err != nil {
-		// log.Printf("Postgres probe: db.Ping failed for %s: %v", p.Addr, err)
-		return Result{Target: p.Addr, Type: "postgres", Status: false, Duration: duration, CheckedAt: time.Now(), Message: err.Error()}
+		return Result{Target: p.Addr, Type: "postgres", Status: false, Duration: duration, CheckedAt: time.Now(), Message: p.failure(err)}
 	}
 
-	// log.Printf("Postgres probe: SUCCESS for %s in %v", p.Addr, duration)
 	return Result{Target: p.Addr, Type: "postgres", Status: true, Duration: duration, CheckedAt: time.Now()}
 }
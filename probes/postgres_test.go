@@ -0,0 +1,35 @@
+package probes
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPostgresDSNEscapesCredentials(t *testing.T) {
+	p := Postgres{Addr: "db.example:5432/app?sslmode=require", User: "monitor", Pass: "p@ss/w:rd?#"}
+	u, err := url.Parse(p.dsn())
+	if err != nil {
+		t.Fatalf("dsn %q does not parse: %v", p.dsn(), err)
+	}
+	if pass, _ := u.User.Password(); pass != p.Pass || u.User.Username() != "monitor" {
+		t.Fatalf("dsn credentials %q, want monitor:%s", u.User, p.Pass)
+	}
+	if u.Host != "db.example:5432" || u.Path != "/app" || u.Query().Get("sslmode") != "require" {
+		t.Fatalf("dsn %q lost the address, database or parameters", p.dsn())
+	}
+
+	// Without a database in the address, the default is used.
+	if dsn := (Postgres{Addr: "db.example:5432"}).dsn(); dsn != "postgres://db.example:5432/postgres?connect_timeout=10&sslmode=disable" {
+		t.Fatalf("dsn = %q", dsn)
+	}
+}
+
+func TestPostgresFailureMasksPassword(t *testing.T) {
+	p := Postgres{Addr: "db.example:5432", User: "monitor", Pass: "p@ss/w:rd?#"}
+	err := errors.New(`parse "` + p.dsn() + `": invalid port`)
+	if msg := p.failure(err); !strings.Contains(msg, "monitor:xxxxx@") {
+		t.Fatalf("failure message %q shows the password", msg)
+	}
+}
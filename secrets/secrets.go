@@ -0,0 +1,174 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reference prefixes understood by Resolve. Anything else is treated as a
+// literal value so existing inline passwords keep working.
+const (
+	envPrefix   = "env:"
+	filePrefix  = "file:"
+	vaultPrefix = "vault:"
+)
+
+// EnvNamePrefix is the prefix of the environment variables env: references
+// may read. Targets are edited through the API, so any other variable, such
+// as VAULT_TOKEN, could otherwise be sent to a host of the editor's choosing.
+const EnvNamePrefix = "UPTIME_"
+
+var vaultClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: &http.Transport{Proxy: nil},
+}
+
+// Values read from Vault are reused for vaultTTL; failed reads are retried
+// after vaultErrorTTL. Once a value has expired it is still returned while a
+// single background read refreshes it, so a slow Vault only delays the first
+// resolution of each reference.
+const (
+	vaultTTL      = 5 * time.Minute
+	vaultErrorTTL = 30 * time.Second
+)
+
+type vaultEntry struct {
+	value      string
+	err        error
+	expires    time.Time
+	refreshing bool
+}
+
+var (
+	vaultMu    sync.Mutex
+	vaultCache = map[string]*vaultEntry{}
+	now        = time.Now
+)
+
+// IsRef reports whether s is a secret reference rather than a literal value.
+func IsRef(s string) bool {
+	return strings.HasPrefix(s, envPrefix) ||
+		strings.HasPrefix(s, filePrefix) ||
+		strings.HasPrefix(s, vaultPrefix)
+}
+
+// Resolve returns the value a reference points at. Supported forms:
+//
+//	env:NAME              environment variable NAME, which must start with UPTIME_
+//	file:/path/to/secret  file contents, trailing newline trimmed
+//	vault:path#field      KV secret read from VAULT_ADDR with VAULT_TOKEN
+//
+// Literal values are returned unchanged.
+func Resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, envPrefix):
+		name := strings.TrimPrefix(ref, envPrefix)
+		if !strings.HasPrefix(name, EnvNamePrefix) {
+			return "", fmt.Errorf("secret env %s is not allowed: names must start with %s", name, EnvNamePrefix)
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret env %s is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(ref, filePrefix):
+		path := strings.TrimPrefix(ref, filePrefix)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(ref, vaultPrefix):
+		return cachedVault(strings.TrimPrefix(ref, vaultPrefix))
+	}
+	return ref, nil
+}
+
+// cachedVault resolves a Vault reference through vaultCache.
+func cachedVault(ref string) (string, error) {
+	vaultMu.Lock()
+	e, ok := vaultCache[ref]
+	switch {
+	case ok && (e.refreshing || now().Before(e.expires)):
+		vaultMu.Unlock()
+		return e.value, e.err
+	case ok && e.err == nil:
+		e.refreshing = true
+		vaultMu.Unlock()
+		go fetchVault(ref)
+		return e.value, nil
+	}
+	vaultMu.Unlock()
+	return fetchVault(ref)
+}
+
+// fetchVault reads a Vault reference and stores the result in vaultCache.
+// A failed refresh keeps the previous value until the next retry.
+func fetchVault(ref string) (string, error) {
+	v, err := resolveVault(ref)
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if err != nil {
+		e := vaultCache[ref]
+		if e != nil && e.err == nil {
+			e.refreshing = false
+			e.expires = now().Add(vaultErrorTTL)
+			return e.value, nil
+		}
+		vaultCache[ref] = &vaultEntry{err: err, expires: now().Add(vaultErrorTTL)}
+		return "", err
+	}
+	vaultCache[ref] = &vaultEntry{value: v, expires: now().Add(vaultTTL)}
+	return v, nil
+}
+
+// resolveVault reads a field from a Vault-compatible KV endpoint. Both KV v1
+// ({"data": {...}}) and KV v2 ({"data": {"data": {...}}}) responses are
+// accepted.
+func resolveVault(ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", errors.New("vault reference must be vault:path#field")
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		addr = "http://127.0.0.1:8200"
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: %s returned %s", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	data := body.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		data = inner
+	}
+	v, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault: field %q not found in %s", field, path)
+	}
+	return v, nil
+}
@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVaultValuesAreCached(t *testing.T) {
+	var reads atomic.Int32
+	var fail atomic.Bool
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		if fail.Load() {
+			http.Error(w, "sealed", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"password": "hunter2"}}}`))
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() {
+		now = time.Now
+		vaultCache = map[string]*vaultEntry{}
+	})
+
+	resolve := func() string {
+		t.Helper()
+		v, err := Resolve("vault:secret/data/pg#password")
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		return v
+	}
+	if v := resolve(); v != "hunter2" {
+		t.Fatalf("resolved %q, want hunter2", v)
+	}
+	resolve()
+	if n := reads.Load(); n != 1 {
+		t.Fatalf("%d Vault reads within the TTL, want 1", n)
+	}
+
+	// An expired value is still returned while it is refreshed, and a
+	// failed refresh keeps it.
+	fail.Store(true)
+	clock = clock.Add(vaultTTL)
+	if v := resolve(); v != "hunter2" {
+		t.Fatalf("resolved %q during refresh, want the cached value", v)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for reads.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := reads.Load(); n != 2 {
+		t.Fatalf("%d Vault reads after expiry, want 2", n)
+	}
+	for {
+		vaultMu.Lock()
+		refreshing := vaultCache["secret/data/pg#password"].refreshing
+		vaultMu.Unlock()
+		if !refreshing || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := resolve(); v != "hunter2" {
+		t.Fatalf("resolved %q after a failed refresh, want the cached value", v)
+	}
+	if n := reads.Load(); n != 2 {
+		t.Fatalf("%d Vault reads before the retry, want 2", n)
+	}
+}
+
+func TestVaultErrorsAreRetried(t *testing.T) {
+	var reads atomic.Int32
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		http.Error(w, "sealed", http.StatusServiceUnavailable)
+	}))
+	defer vault.Close()
+	t.Setenv("VAULT_ADDR", vault.URL)
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() {
+		now = time.Now
+		vaultCache = map[string]*vaultEntry{}
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := Resolve("vault:secret/data/pg#password"); err == nil {
+			t.Fatal("resolved a reference Vault refused")
+		}
+	}
+	if n := reads.Load(); n != 1 {
+		t.Fatalf("%d Vault reads, want the error to be cached", n)
+	}
+	clock = clock.Add(vaultErrorTTL)
+	Resolve("vault:secret/data/pg#password")
+	if n := reads.Load(); n != 2 {
+		t.Fatalf("%d Vault reads after vaultErrorTTL, want 2", n)
+	}
+}
+
+func TestEnvReferencesNeedPrefix(t *testing.T) {
+	t.Setenv("UPTIME_PG_PASSWORD", "hunter2")
+	t.Setenv("VAULT_TOKEN", "s.root")
+	if v, err := Resolve("env:UPTIME_PG_PASSWORD"); err != nil || v != "hunter2" {
+		t.Fatalf("env:UPTIME_PG_PASSWORD = %q, %v", v, err)
+	}
+	if v, err := Resolve("env:VAULT_TOKEN"); err == nil {
+		t.Fatalf("env:VAULT_TOKEN resolved to %q, want an error", v)
+	}
+}
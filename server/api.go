@@ -2,9 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +21,7 @@ var mu sync.RWMutex
 var settings = &Settings{Frequency: 60, TimeframeHours: 24}
 
 type CheckResponse struct {
+	ID        int64     `json:"id,omitempty"`
 	Target    string    `json:"target"`
 	Type      string    `json:"type"`
 	Status    bool      `json:"status"`
@@ -26,18 +30,76 @@ type CheckResponse struct {
 	Message   string    `json:"message"`
 }
 
+func newCheckResponse(d probes.Result) CheckResponse {
+	return CheckResponse{
+		Target:    d.Target,
+		Type:      d.Type,
+		Status:    d.Status,
+		Duration:  d.Duration.Milliseconds(), // Convert to ms
+		CheckedAt: d.CheckedAt,
+		Message:   d.Message,
+	}
+}
+
+// registerAPI registers the routes of apiRoutes, wrapping each handler in
+// audited and allow as its entry asks.
+//
+// httpmux cannot match a static segment where a route of the same method
+// has a wildcard, such as POST /targets/clear next to POST
+// /targets/{id}/check. Such static routes are served by a handler on the
+// wildcard path instead, which picks them by the segment's value.
 func registerAPI(mux *httpmux.Router) {
-	mux.GET("/checks", handleChecks)
-	mux.GET("/settings", handleSettings)
-	mux.POST("/settings", handleSettings)
-	mux.GET("/targets", handleTargets)
-	mux.POST("/targets", handleTargets)
-	mux.PUT("/targets", handleTargets)
-	mux.DELETE("/targets", handleTargets)
-	mux.POST("/targets/clear", handleClear)
-	mux.POST("/targets/subscribe", handleSubscribe)
-	mux.POST("/targets/unsubscribe", handleUnsubscribe)
-	mux.POST("/test-telegram", handleTestTelegram)
+	type route struct{ method, path string }
+	var routes []route
+	handlers := make(map[route]http.HandlerFunc)
+	wildcards := make(map[route]bool) // method and directory of a {id} segment
+	for _, rt := range apiRoutes() {
+		h := rt.Handler
+		if rt.Audit != nil {
+			h = audited(rt.Audit, h)
+		}
+		if rt.Role != "" {
+			h = allow(rt.Role, h)
+		}
+		r := route{rt.Method, rt.Path}
+		routes = append(routes, r)
+		handlers[r] = h
+		if dir, _, ok := strings.Cut(rt.Path, "{id}"); ok {
+			wildcards[route{rt.Method, dir}] = true
+		}
+	}
+
+	shadowed := make(map[route]map[string]http.HandlerFunc)
+	for _, r := range routes {
+		dir, name := path.Split(r.path)
+		if strings.Contains(r.path, "{") || !wildcards[route{r.method, dir}] {
+			continue
+		}
+		w := route{r.method, dir + "{id}"}
+		if shadowed[w] == nil {
+			shadowed[w] = make(map[string]http.HandlerFunc)
+		}
+		shadowed[w][name] = handlers[r]
+		delete(handlers, r)
+	}
+
+	for _, r := range routes {
+		if h, ok := handlers[r]; ok && shadowed[r] == nil {
+			mux.HandleFunc(r.method, r.path, h)
+		}
+	}
+	for w, static := range shadowed {
+		own := handlers[w]
+		mux.HandleFunc(w.method, w.path, func(rw http.ResponseWriter, req *http.Request) {
+			if h, ok := static[req.PathValue("id")]; ok {
+				h(rw, req)
+			} else if own != nil {
+				own(rw, req)
+			} else {
+				http.NotFound(rw, req)
+			}
+		})
+	}
 }
 
 func handleChecks(w http.ResponseWriter, r *http.Request) {
@@ -52,14 +114,7 @@ func handleChecks(w http.ResponseWriter, r *http.Request) {
 		// Convert data to CheckResponse to send duration in milliseconds
 		response_data := make([]CheckResponse, len(data))
 		for i, d := range data {
-			response_data[i] = CheckResponse{
-				Target:    d.Target,
-				Type:      d.Type,
-				Status:    d.Status,
-				Duration:  d.Duration.Milliseconds(), // Convert to ms
-				CheckedAt: d.CheckedAt,
-				Message:   d.Message,
-			}
+			response_data[i] = newCheckResponse(d)
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response_data)
@@ -68,13 +123,32 @@ func handleChecks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Health is the response of GET /health. CheckWriter counts check results
+// that were accepted but could not be stored.
+type Health struct {
+	CheckWriter storage.WriteStatus `json:"checkWriter"`
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Health{CheckWriter: storage.WriterStatus()})
+}
+
 func handleSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		mu.RLock()
 		defer mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(settings)
 	case http.MethodPost:
+		mu.RLock()
+		managed := settingsManaged
+		mu.RUnlock()
+		if managed {
+			http.Error(w, "settings are managed by the config file", http.StatusConflict)
+			return
+		}
 		var s Settings
 		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
 			http.Error(w, err.Error(), 400)
@@ -95,96 +169,113 @@ func handleTargets(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		targets, err := storage.GetTargetInfos()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(targets)
 	case http.MethodPost:
-		var t struct {
-			Name     string `json:"name"`
-			URL      string `json:"url"`
-			Type     string `json:"type"`
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}
+		var t TargetRequest
 		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		fields, err := validateTarget(0, t.Name, t.URL, t.Type)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		if err := storage.AddTarget(t.Name, t.URL, t.Type, t.Username, t.Password); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if len(fields) > 0 {
+			writeError(w, http.StatusBadRequest, "invalid target", fields...)
+			return
+		}
+		id, err := storage.AddTarget(t.Name, t.URL, t.Type, t.Username, t.Password)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
 		// Perform an immediate check in the background
 		go func() {
-			var probe probes.Target
-			switch t.Type {
-			case "http":
-				probe = probes.HTTP{URL: t.URL}
-			case "postgres":
-				probe = probes.Postgres{Addr: t.URL, User: t.Username, Pass: t.Password, DB: "postgres"}
-			case "redis":
-				probe = probes.Redis{Addr: t.URL, User: t.Username, Pass: t.Password}
-			}
-			if probe != nil {
-				res := probe.Check()
-				if err := storage.SaveCheck(res); err != nil {
-					log.Println("save error on initial check:", err)
-				}
+			if _, err := checkTarget(id, checkTimeout); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Println("initial check error:", err)
 			}
 		}()
 
-		w.WriteHeader(http.StatusCreated)
+		w.Header().Set("Location", "/api/targets/"+strconv.Itoa(id))
+		writeTarget(w, http.StatusCreated, id)
 	case http.MethodPut:
-		var t struct {
-			ID       int    `json:"id"`
-			Name     string `json:"name"`
-			URL      string `json:"url"`
-			Type     string `json:"type"`
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}
+		var t TargetRequest
 		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if rejectManaged(w, t.ID) {
+			return
+		}
+		fields, err := validateTarget(t.ID, t.Name, t.URL, t.Type)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(fields) > 0 {
+			writeError(w, http.StatusBadRequest, "invalid target", fields...)
 			return
 		}
 		if err := storage.UpdateTarget(t.ID, t.Name, t.URL, t.Type, t.Username, t.Password); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStorageError(w, err)
 			return
 		}
 		// Reset the loop to apply changes immediately
 		ResetMonitorLoop()
-		w.WriteHeader(http.StatusOK)
+		writeTarget(w, http.StatusOK, t.ID)
 	case http.MethodDelete:
 		idStr := r.URL.Query().Get("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			http.Error(w, "invalid id", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, "invalid id")
 			return
 		}
-		if err := storage.DeleteTarget(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if rejectManaged(w, id) {
+			return
+		}
+		if err := storage.DeleteTarget(id, clock.Now()); err != nil {
+			writeStorageError(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// rejectManaged writes a 409 and returns true if the target is managed by
+// the config file.
+func rejectManaged(w http.ResponseWriter, id int) bool {
+	managed, err := storage.IsManaged(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	if managed {
+		writeError(w, http.StatusConflict, storage.ErrManaged.Error())
+		return true
 	}
+	return false
 }
 
 func handleClear(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 	target := r.URL.Query().Get("target")
 	if target == "" {
-		http.Error(w, "missing target", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "missing target")
 		return
 	}
 	if err := storage.ClearChecks(target); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -192,17 +283,20 @@ func handleClear(w http.ResponseWriter, r *http.Request) {
 
 func handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if rejectManaged(w, id) {
 		return
 	}
 	if err := storage.SubscribeTarget(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -210,17 +304,20 @@ func handleSubscribe(w http.ResponseWriter, r *http.Request) {
 
 func handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if rejectManaged(w, id) {
 		return
 	}
 	if err := storage.UnsubscribeTarget(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -237,10 +334,32 @@ func handleTestTelegram(w http.ResponseWriter, r *http.Request) {
 
 // Settings for monitor frequency and timeframe
 type Settings struct {
-	Frequency      int `json:"frequency"` // seconds
-	TimeframeHours int `json:"timeframeHours"`
+	Frequency      int `json:"frequency" yaml:"frequency"` // seconds
+	TimeframeHours int `json:"timeframeHours" yaml:"timeframeHours"`
+}
+
+// loadSettings replaces the in-memory settings and stored Telegram notifier
+// with the stored ones.
+func loadSettings() {
+	s, err := storage.GetSettings()
+	if err != nil {
+		return
+	}
+	t, err := storage.GetTelegramSettings()
+	if err != nil {
+		return
+	}
+	mu.Lock()
+	settings = &Settings{Frequency: s.Frequency, TimeframeHours: s.TimeframeHours}
+	storedTelegram = nil
+	if t != nil {
+		storedTelegram = &TelegramConfig{BotToken: t.BotToken, ChatID: t.ChatID}
+	}
+	mu.Unlock()
 }
 
 func GetFrequency() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
 	return time.Duration(settings.Frequency) * time.Second
 }
@@ -0,0 +1,422 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"uptime/secrets"
+	"uptime/storage"
+)
+
+const auditKey contextKey = principalKey + 1
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// auditRecord is filled in by audited while a request is handled.
+type auditRecord struct {
+	resource      string
+	before, after any
+}
+
+// auditRequests records every mutating API request in the audit log with the
+// caller, source IP and response status, plus the resource snapshots taken
+// by audited. It runs inside requireAuth, so the caller is known.
+func auditRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || safeMethod(r.Method) || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &auditRecord{}
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sr, r.WithContext(context.WithValue(r.Context(), auditKey, rec)))
+
+		e := storage.AuditEntry{
+			At:       clock.Now(),
+			Actor:    "anonymous",
+			SourceIP: sourceIP(r),
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Status:   sr.status,
+			Resource: rec.resource,
+		}
+		if p := principal(r); p != nil {
+			e.Actor, e.Role, e.TokenID = p.Username, p.Role, p.TokenID
+		}
+		recordAudit(e, rec.before, rec.after)
+	})
+}
+
+// recordAudit fills in the before/after snapshots and their diff and stores
+// the entry. Failures are logged; they never fail the request.
+func recordAudit(e storage.AuditEntry, before, after any) {
+	var err error
+	if e.Before, err = json.Marshal(before); err == nil {
+		e.After, err = json.Marshal(after)
+	}
+	if err == nil {
+		e.Changes, err = diffJSON(e.Before, e.After)
+	}
+	if err == nil {
+		err = storage.AddAuditEntry(e)
+	}
+	if err != nil {
+		log.Printf("audit: %s %s %s: %v", e.Actor, e.Method, e.Path, err)
+	}
+}
+
+// diffJSON compares two JSON objects field by field and returns the fields
+// that differ as {"field": [before, after]}. It returns nil unless both are
+// objects, e.g. for creates and deletes.
+func diffJSON(before, after []byte) (json.RawMessage, error) {
+	var b, a map[string]any
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &a) != nil || b == nil || a == nil {
+		return nil, nil
+	}
+	changes := map[string][2]any{}
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			changes[k] = [2]any{v, a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = [2]any{nil, v}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+// sourceIP is the client address, or the first X-Forwarded-For entry when
+// TRUST_PROXY_HEADERS=true because the server runs behind a reverse proxy.
+func sourceIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			ip, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// snapshot returns the resource a request changes and its current state,
+// nil when it does not exist.
+type snapshot func() (resource string, state any)
+
+// subjectFunc identifies the resource changed by a request.
+type subjectFunc func(r *http.Request) snapshot
+
+// audited wraps h so that the audit entry of the request gets the state of
+// its subject before and after h ran.
+func audited(subject subjectFunc, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec, _ := r.Context().Value(auditKey).(*auditRecord)
+		if rec == nil {
+			h(w, r)
+			return
+		}
+		snap := subject(r)
+		rec.resource, rec.before = snap()
+		h(w, r)
+		resource, after := snap()
+		if resource != "" {
+			rec.resource = resource
+		}
+		rec.after = after
+	}
+}
+
+// peekBody decodes the JSON request body into v and leaves the body in place
+// for the handler.
+func peekBody(r *http.Request, v any) {
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	if err == nil {
+		json.Unmarshal(b, v)
+	}
+}
+
+func pathID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	return id
+}
+
+// findTarget returns the target with the given ID, or else the newest one
+// with the given name, without its volatile monitor state.
+func findTarget(id int, name string) (string, any) {
+	infos, err := storage.GetTargetInfos()
+	if err != nil {
+		return "", nil
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		t := infos[i]
+		if (id != 0 && t.ID == id) || (id == 0 && name != "" && t.Name == name) {
+			t.State = nil
+			return "target:" + strconv.Itoa(t.ID), &t
+		}
+	}
+	if id != 0 {
+		return "target:" + strconv.Itoa(id), nil
+	}
+	return "", nil
+}
+
+// targetByQuery is the target of ?id=.
+func targetByQuery(r *http.Request) snapshot {
+	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+	return func() (string, any) { return findTarget(id, "") }
+}
+
+// targetByPath is the target of /targets/{id}.
+func targetByPath(r *http.Request) snapshot {
+	id := int(pathID(r))
+	return func() (string, any) { return findTarget(id, "") }
+}
+
+// targetByBody is the target of a body's id or, on create, name.
+func targetByBody(r *http.Request) snapshot {
+	var body struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	peekBody(r, &body)
+	return func() (string, any) { return findTarget(body.ID, body.Name) }
+}
+
+// targetsByBody are the targets of a body's ids, as in bulk requests.
+func targetsByBody(r *http.Request) snapshot {
+	var body struct {
+		IDs []int `json:"ids"`
+	}
+	peekBody(r, &body)
+	ids := make([]string, len(body.IDs))
+	for i, id := range body.IDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	return func() (string, any) {
+		targets := map[string]any{}
+		for _, id := range body.IDs {
+			if _, t := findTarget(id, ""); t != nil {
+				targets[strconv.Itoa(id)] = t
+			}
+		}
+		return "targets:" + strings.Join(ids, ","), targets
+	}
+}
+
+// checksByTarget is the stored check count of ?target= (a target URL).
+func checksByTarget(r *http.Request) snapshot {
+	target := r.URL.Query().Get("target")
+	return func() (string, any) {
+		n, err := storage.CountChecks(target)
+		if err != nil {
+			return "checks:" + target, nil
+		}
+		return "checks:" + target, map[string]any{"target": target, "checks": n}
+	}
+}
+
+func settingsSubject(r *http.Request) snapshot {
+	return func() (string, any) {
+		mu.RLock()
+		defer mu.RUnlock()
+		return "settings", map[string]any{
+			"frequency":      settings.Frequency,
+			"timeframeHours": settings.TimeframeHours,
+			"managed":        settingsManaged,
+		}
+	}
+}
+
+// sloSubject is the SLO of the path, or on create the newest one named in
+// the body.
+func sloSubject(r *http.Request) snapshot {
+	id := pathID(r)
+	var body struct {
+		Name string `json:"name"`
+	}
+	if id == 0 {
+		peekBody(r, &body)
+	}
+	return func() (string, any) {
+		slos, _ := storage.ListSLOs()
+		for i := len(slos) - 1; i >= 0; i-- {
+			if (id != 0 && slos[i].ID == id) || (id == 0 && slos[i].Name == body.Name) {
+				return "slo:" + strconv.FormatInt(slos[i].ID, 10), &slos[i]
+			}
+		}
+		if id != 0 {
+			return "slo:" + strconv.FormatInt(id, 10), nil
+		}
+		return "", nil
+	}
+}
+
+// reportSubject is the report of the path, or on create the newest one named
+// in the body.
+func reportSubject(r *http.Request) snapshot {
+	id := pathID(r)
+	var body struct {
+		Name string `json:"name"`
+	}
+	if id == 0 {
+		peekBody(r, &body)
+	}
+	return func() (string, any) {
+		reports, _ := storage.ListReports()
+		for i := len(reports) - 1; i >= 0; i-- {
+			if (id != 0 && reports[i].ID == id) || (id == 0 && reports[i].Name == body.Name) {
+				rep := reports[i]
+				rep.LastRunAt = nil
+				return "report:" + strconv.FormatInt(rep.ID, 10), &rep
+			}
+		}
+		if id != 0 {
+			return "report:" + strconv.FormatInt(id, 10), nil
+		}
+		return "", nil
+	}
+}
+
+// userSubject is the user of the path, or on create the one named in the
+// body.
+func userSubject(r *http.Request) snapshot {
+	id := pathID(r)
+	var body struct {
+		Username string `json:"username"`
+	}
+	if id == 0 {
+		peekBody(r, &body)
+	}
+	return func() (string, any) {
+		users, _ := storage.ListUsers()
+		for i := range users {
+			if (id != 0 && users[i].ID == id) || (id == 0 && users[i].Username == body.Username) {
+				return "user:" + strconv.FormatInt(users[i].ID, 10), &users[i]
+			}
+		}
+		if id != 0 {
+			return "user:" + strconv.FormatInt(id, 10), nil
+		}
+		return "", nil
+	}
+}
+
+func incidentSubject(r *http.Request) snapshot {
+	id := pathID(r)
+	return func() (string, any) {
+		inc, err := storage.GetIncident(id)
+		if err != nil {
+			return "incident:" + strconv.FormatInt(id, 10), nil
+		}
+		return "incident:" + strconv.FormatInt(id, 10), inc
+	}
+}
+
+func tokenSubject(r *http.Request) snapshot {
+	id := pathID(r)
+	return func() (string, any) {
+		tokens, _ := storage.ListTokens(0)
+		for i := range tokens {
+			if tokens[i].ID == id {
+				return "token:" + strconv.FormatInt(id, 10), &tokens[i]
+			}
+		}
+		return "token:" + strconv.FormatInt(id, 10), nil
+	}
+}
+
+// configSnapshot is the state the config file and imports can change:
+// settings, targets and the Telegram notifier. Notifier credentials are only
+// shown when they are secret references.
+func configSnapshot() any {
+	_, s := settingsSubject(nil)()
+	infos, _ := storage.GetTargetInfos()
+	for i := range infos {
+		infos[i].State = nil
+	}
+	cfg, _ := currentTelegramConfig()
+	var telegram any
+	if cfg != nil {
+		redact := func(v string) string {
+			if secrets.IsRef(v) {
+				return v
+			}
+			return "(redacted)"
+		}
+		telegram = map[string]string{"botToken": redact(cfg.BotToken), "chatId": redact(cfg.ChatID)}
+	}
+	return map[string]any{"settings": s, "targets": infos, "telegram": telegram}
+}
+
+func configSubject(r *http.Request) snapshot {
+	return func() (string, any) { return "config", configSnapshot() }
+}
+
+// handleAudit serves GET /audit, newest entries first, with optional actor,
+// resource (e.g. target:3, or target: for every target), from/to (RFC 3339),
+// limit and before (an entry ID to page past) query parameters.
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := storage.AuditQuery{Actor: query.Get("actor"), Resource: query.Get("resource")}
+	var err error
+	if q.From, err = parseTimeParam(query.Get("from")); err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.To, err = parseTimeParam(query.Get("to")); err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s := query.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("before"); s != "" {
+		if q.Before, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := storage.ListAudit(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
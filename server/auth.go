@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"uptime/secrets"
+	"uptime/storage"
+)
+
+// The SPA logs in with POST /api/auth/login and is then authenticated by the
+// session cookie. Because cookies are sent automatically, mutating requests
+// must also echo the session's CSRF token (returned by login and /auth/me
+// and readable from the csrf cookie) in the X-CSRF-Token header.
+const (
+	sessionCookie = "uptime_session"
+	csrfCookie    = "uptime_csrf"
+	csrfHeader    = "X-CSRF-Token"
+	sessionTTL    = 7 * 24 * time.Hour
+
+	minPasswordLength = 8
+)
+
+type contextKey int
+
+const principalKey contextKey = iota
+
+// Principal is the authenticated caller of an API request. TokenID and
+// Scope are set when the request was made with an API token.
+type Principal struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	TokenID  int64  `json:"tokenId,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	csrfToken string
+}
+
+// principal returns the caller attached by requireAuth, or nil.
+func principal(r *http.Request) *Principal {
+	p, _ := r.Context().Value(principalKey).(*Principal)
+	return p
+}
+
+// publicPaths can be requested without being logged in: the routes marked
+// Public in apiRoutes.
+var publicPaths = func() map[string]bool {
+	paths := map[string]bool{}
+	for _, rt := range apiRoutes() {
+		if rt.Public {
+			paths["/api"+rt.Path] = true
+		}
+	}
+	return paths
+}()
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// requireAuth rejects API requests that carry neither a valid session nor a
+// valid API token (Authorization: Bearer), session requests that mutate
+// without the matching CSRF token, and read-only tokens on anything but safe
+// methods. Paths outside /api are served as they are.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, err := authenticate(r)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !safeMethod(r.Method) {
+			if p.TokenID != 0 && p.Scope != storage.ScopeWrite {
+				http.Error(w, "token is read-only", http.StatusForbidden)
+				return
+			}
+			if p.TokenID == 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(p.csrfToken)) != 1 {
+				http.Error(w, "missing or invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey, p)))
+	})
+}
+
+// authenticate identifies the caller from a bearer token or, failing that,
+// the session cookie. It returns storage.ErrNotFound if there is neither.
+func authenticate(r *http.Request) (*Principal, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		secret, ok := strings.CutPrefix(h, "Bearer ")
+		if !ok {
+			return nil, storage.ErrNotFound
+		}
+		t, err := storage.LookupToken(strings.TrimSpace(secret), clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{UserID: t.UserID, Username: t.Username, Role: t.Role, TokenID: t.ID, Scope: t.Scope}, nil
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	sess, err := storage.GetSession(c.Value, clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{UserID: sess.ID, Username: sess.Username, Role: sess.Role, csrfToken: sess.CSRFToken}, nil
+}
+
+// allow wraps h so that it is only served to callers with at least role.
+// Every authenticated caller is at least a viewer, so read-only endpoints are
+// registered without it.
+func allow(role string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p := principal(r); p == nil || !storage.RoleAtLeast(p.Role, role) {
+			http.Error(w, "requires the "+role+" role", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// LoginRequest is the body of POST /auth/login.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// PasswordRequest is the body of POST /auth/password.
+type PasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// AuthResponse is returned by /auth/login and /auth/me. CSRFToken is empty
+// for API tokens, which do not need one.
+type AuthResponse struct {
+	User      Principal `json:"user"`
+	CSRFToken string    `json:"csrfToken,omitempty"`
+}
+
+// handleLogin serves POST /auth/login with {"username": "...", "password":
+// "..."} and starts a session.
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := storage.Authenticate(body.Username, body.Password)
+	if errors.Is(err, storage.ErrInvalidCredentials) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	csrf, err := startSession(w, r, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthResponse{User: Principal{UserID: user.ID, Username: user.Username, Role: user.Role}, CSRFToken: csrf})
+}
+
+// startSession creates a session for user and sets its cookies. It returns
+// the session's CSRF token.
+func startSession(w http.ResponseWriter, r *http.Request, user *storage.User) (string, error) {
+	now := clock.Now()
+	if err := storage.DeleteExpiredSessions(now); err != nil {
+		log.Println("session cleanup error:", err)
+	}
+	token, csrf, err := storage.CreateSession(user.ID, now.Add(sessionTTL))
+	if err != nil {
+		return "", err
+	}
+	secure := r.TLS != nil
+	maxAge := int(sessionTTL.Seconds())
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", MaxAge: maxAge,
+		HttpOnly: true, Secure: secure, SameSite: http.SameSiteLaxMode})
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: csrf, Path: "/", MaxAge: maxAge,
+		Secure: secure, SameSite: http.SameSiteLaxMode})
+	return csrf, nil
+}
+
+// handleLogout serves POST /auth/logout, ending the current session.
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if err := storage.DeleteSession(c.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, name := range []string{sessionCookie, csrfCookie} {
+		http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMe serves GET /auth/me, returning the caller and, for sessions, the
+// CSRF token.
+func handleMe(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthResponse{User: *p, CSRFToken: p.csrfToken})
+}
+
+// handlePassword serves POST /auth/password with {"currentPassword": "...",
+// "newPassword": "..."}. Changing the password ends every session of the
+// user, including the current one.
+func handlePassword(w http.ResponseWriter, r *http.Request) {
+	var body PasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := principal(r)
+	if _, err := storage.Authenticate(p.Username, body.CurrentPassword); errors.Is(err, storage.ErrInvalidCredentials) {
+		http.Error(w, "current password is wrong", http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(body.NewPassword) < minPasswordLength {
+		http.Error(w, "new password is too short", http.StatusBadRequest)
+		return
+	}
+	if err := storage.SetPassword(p.UserID, body.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ensureAdmin creates the initial admin account on first run. The username
+// comes from ADMIN_USERNAME (default admin) and the password from
+// ADMIN_PASSWORD, which may be a secret reference; without one a random
+// password is generated and logged once.
+func ensureAdmin() error {
+	n, err := storage.CountUsers()
+	if err != nil || n > 0 {
+		return err
+	}
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+	password, err := secrets.Resolve(os.Getenv("ADMIN_PASSWORD"))
+	if err != nil {
+		return err
+	}
+	generated := password == ""
+	if generated {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if _, err := storage.CreateUser(username, password, storage.RoleAdmin, clock.Now()); err != nil {
+		return err
+	}
+	if generated {
+		log.Printf("created admin user %q with password %q; change it after logging in", username, password)
+	} else {
+		log.Printf("created admin user %q", username)
+	}
+	return nil
+}
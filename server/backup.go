@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"uptime/storage"
+)
+
+// Backups are configured through the environment:
+//
+//	BACKUP_DIR       directory for backups (default persistent/backups)
+//	BACKUP_KEEP      number of backups to keep (default 7)
+//	BACKUP_INTERVAL  how often to back up, e.g. 24h (default off)
+func backupDir() string {
+	if dir := os.Getenv("BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return "persistent/backups"
+}
+
+func backupKeep() int {
+	if n, err := strconv.Atoi(os.Getenv("BACKUP_KEEP")); err == nil {
+		return n
+	}
+	return 7
+}
+
+// startBackupSchedule runs a backup every BACKUP_INTERVAL, if set.
+func startBackupSchedule() {
+	s := os.Getenv("BACKUP_INTERVAL")
+	if s == "" {
+		return
+	}
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval <= 0 {
+		log.Printf("invalid BACKUP_INTERVAL %q, scheduled backups disabled", s)
+		return
+	}
+	go func() {
+		for {
+			<-clock.After(interval)
+			if b, err := storage.Backup(backupDir(), backupKeep()); err != nil {
+				log.Println("scheduled backup failed:", err)
+			} else {
+				log.Println("scheduled backup written to", b.Path)
+			}
+		}
+	}()
+}
+
+func handleBackups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		backups, err := storage.ListBackups(backupDir())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if backups == nil {
+			backups = []storage.BackupInfo{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(backups)
+	case http.MethodPost:
+		b, err := storage.Backup(backupDir(), backupKeep())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(b)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"uptime/storage"
+)
+
+// CheckPage is one page of a target's check history.
+type CheckPage struct {
+	Checks     []CheckResponse `json:"checks"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+// handleTargetChecks serves GET /targets/{id}/checks with optional
+// from/to (RFC 3339), status (up|down), limit and cursor query parameters.
+func handleTargetChecks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	query := r.URL.Query()
+	q := storage.CheckQuery{TargetID: id}
+
+	if q.From, err = parseTimeParam(query.Get("from")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if q.To, err = parseTimeParam(query.Get("to")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	switch query.Get("status") {
+	case "":
+	case "up":
+		up := true
+		q.Status = &up
+	case "down":
+		down := false
+		q.Status = &down
+	default:
+		writeError(w, http.StatusBadRequest, "status must be up or down")
+		return
+	}
+	if s := query.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+	if s := query.Get("cursor"); s != "" {
+		if q.After, err = parseCheckCursor(s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	if err := storage.TargetExists(id); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	records, next, err := storage.QueryChecks(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	page := CheckPage{Checks: make([]CheckResponse, len(records))}
+	for i, rec := range records {
+		page.Checks[i] = newCheckResponse(rec.Result)
+		page.Checks[i].ID = rec.ID
+	}
+	if next != nil {
+		page.NextCursor = formatCheckCursor(next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// formatCheckCursor encodes a cursor as "<checked_at in Unix nanoseconds>-<id>".
+// Clients treat it as opaque.
+func formatCheckCursor(c *storage.CheckCursor) string {
+	return strconv.FormatInt(c.CheckedAt.UnixNano(), 10) + "-" + strconv.FormatInt(c.ID, 10)
+}
+
+func parseCheckCursor(s string) (*storage.CheckCursor, error) {
+	at, id, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, errors.New("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	c := &storage.CheckCursor{CheckedAt: time.Unix(0, nanos).UTC()}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter.
+func parseTimeParam(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+const maxSeriesBuckets = 5000
+
+// handleTargetSeries serves GET /targets/{id}/series?from=&to=&step= with
+// server-side aggregated buckets. from/to default to the configured
+// timeframe ending now; step is a Go duration ("5m") or seconds and defaults
+// to about 100 buckets.
+func handleTargetSeries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	query := r.URL.Query()
+
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+		return
+	}
+	if to.IsZero() {
+		to = clock.Now()
+	}
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+		return
+	}
+	if from.IsZero() {
+		mu.RLock()
+		from = to.Add(-time.Duration(settings.TimeframeHours) * time.Hour)
+		mu.RUnlock()
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	step := max((to.Sub(from) / 100).Truncate(time.Minute), time.Minute)
+	if s := query.Get("step"); s != "" {
+		if step, err = parseStep(s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid step")
+			return
+		}
+	}
+	if step < time.Second {
+		writeError(w, http.StatusBadRequest, "step must be at least 1s")
+		return
+	}
+	if to.Sub(from)/step > maxSeriesBuckets {
+		writeError(w, http.StatusBadRequest, "too many buckets, increase step")
+		return
+	}
+
+	if err := storage.TargetExists(id); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	buckets, err := storage.CheckSeries(id, from, to, step)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
+
+// parseStep accepts a Go duration string or a number of seconds.
+func parseStep(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,71 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"uptime/server"
+	"uptime/server/servertest"
+)
+
+// wantAPIError requests path and fails unless the answer is code with a
+// JSON error body.
+func wantAPIError(t *testing.T, h *servertest.Harness, path string, code int) {
+	t.Helper()
+	got, body := get(t, h.Client, h.Server.URL+path)
+	var apiErr server.APIError
+	if got != code || json.Unmarshal([]byte(body), &apiErr) != nil || apiErr.Error == "" {
+		t.Errorf("GET %s: %d %s, want %d with a JSON error", path, got, body, code)
+	}
+}
+
+func TestTargetChecks(t *testing.T) {
+	h := servertest.New(t)
+	id := h.AddTarget("api", "stub://api", false)
+	h.Tick(3 * time.Minute)
+	path := "/api/targets/" + strconv.Itoa(id) + "/checks"
+
+	var ids []int64
+	for next := "?limit=2"; next != ""; {
+		code, body := get(t, h.Client, h.Server.URL+path+next)
+		var page server.CheckPage
+		if code != http.StatusOK || json.Unmarshal([]byte(body), &page) != nil {
+			t.Fatalf("GET %s%s: %d %s", path, next, code, body)
+		}
+		for _, c := range page.Checks {
+			ids = append(ids, c.ID)
+		}
+		next = ""
+		if page.NextCursor != "" {
+			next = "?limit=2&cursor=" + page.NextCursor
+		}
+	}
+	if len(ids) != 3 || ids[0] < ids[1] || ids[1] < ids[2] {
+		t.Fatalf("paged check IDs %v, want 3 newest first", ids)
+	}
+
+	wantAPIError(t, h, "/api/targets/"+strconv.Itoa(id+1)+"/checks", http.StatusNotFound)
+	wantAPIError(t, h, "/api/targets/x/checks", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?cursor=abc", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?status=sideways", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?limit=0", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?from=yesterday", http.StatusBadRequest)
+}
+
+func TestTargetSeriesErrors(t *testing.T) {
+	h := servertest.New(t)
+	id := h.AddTarget("api", "stub://api", false)
+	path := "/api/targets/" + strconv.Itoa(id) + "/series"
+	if code, body := get(t, h.Client, h.Server.URL+path); code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", path, code, body)
+	}
+
+	wantAPIError(t, h, "/api/targets/"+strconv.Itoa(id+1)+"/series", http.StatusNotFound)
+	wantAPIError(t, h, "/api/targets/x/series", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?step=500ms", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?step=1s&from=2023-01-01T00:00:00Z", http.StatusBadRequest)
+	wantAPIError(t, h, path+"?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest)
+}
@@ -0,0 +1,26 @@
+package server
+
+import "time"
+
+// Clock abstracts time so the monitor loop and notification throttling can be
+// driven by a fake clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var clock Clock = realClock{}
+
+// SetClock replaces the clock used by the server. It must be called while
+// the monitor loop is stopped.
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	clock = c
+}
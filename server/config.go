@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"uptime/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 5 * time.Second
+
+// Config is the declarative configuration file. YAML and JSON are both
+// accepted:
+//
+//	settings:
+//	  frequency: 60
+//	  timeframeHours: 24
+//	notifications:
+//	  telegram:
+//	    botToken: env:UPTIME_TELEGRAM_BOT_TOKEN
+//	    chatId: "123456"
+//	targets:
+//	  - name: API
+//	    url: https://api.example.com/health
+//	    type: http
+//	    subscribed: true
+//
+// Declared targets are created, updated and deleted to match the file and
+// are read-only in the API. Declared settings are read-only as well.
+type Config struct {
+	Settings      *Settings          `yaml:"settings" json:"settings"`
+	Notifications NotificationConfig `yaml:"notifications" json:"notifications"`
+	Targets       []TargetConfig     `yaml:"targets" json:"targets"`
+}
+
+type NotificationConfig struct {
+	Telegram *TelegramConfig `yaml:"telegram" json:"telegram"`
+}
+
+// TelegramConfig overrides TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID. Both
+// values may be secret references.
+type TelegramConfig struct {
+	BotToken string `yaml:"botToken" json:"botToken"`
+	ChatID   string `yaml:"chatId" json:"chatId"`
+}
+
+type TargetConfig struct {
+	Name       string `yaml:"name" json:"name"`
+	URL        string `yaml:"url" json:"url"`
+	Type       string `yaml:"type" json:"type"`
+	Username   string `yaml:"username" json:"username"`
+	Password   string `yaml:"password" json:"password"`
+	Subscribed bool   `yaml:"subscribed" json:"subscribed"`
+}
+
+var (
+	// settingsManaged is set while the config file declares settings.
+	settingsManaged bool
+	telegramConfig  *TelegramConfig
+	// storedTelegram is the Telegram notifier set by an import, used when
+	// the config file declares none.
+	storedTelegram *TelegramConfig
+)
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(b)
+}
+
+func parseConfig(b []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if s := cfg.Settings; s != nil && (s.Frequency <= 0 || s.TimeframeHours <= 0) {
+		return nil, errors.New("config: settings.frequency and settings.timeframeHours must be positive")
+	}
+	names := make(map[string]bool, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		if t.Name == "" || t.URL == "" {
+			return nil, fmt.Errorf("config: targets[%d]: name and url are required", i)
+		}
+		if !storage.IsProbeType(t.Type) {
+			return nil, fmt.Errorf("config: target %q: unknown type %q", t.Name, t.Type)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("config: duplicate target name %q", t.Name)
+		}
+		names[t.Name] = true
+	}
+	return &cfg, nil
+}
+
+// applyConfig reconciles the database and in-memory settings with cfg.
+func applyConfig(cfg *Config) error {
+	specs := make([]storage.TargetSpec, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		specs[i] = storage.TargetSpec{Name: t.Name, URL: t.URL, Type: t.Type, Username: t.Username, Password: t.Password, Subscribed: t.Subscribed}
+	}
+	res, err := storage.ReconcileTargets(specs, clock.Now())
+	if err != nil {
+		return err
+	}
+	if len(res.Created)+len(res.Updated)+len(res.Deleted) > 0 {
+		log.Printf("config: created %v, updated %v, deleted %v", res.Created, res.Updated, res.Deleted)
+	}
+
+	if cfg.Settings != nil {
+		if err := storage.UpdateSettings(cfg.Settings.Frequency, cfg.Settings.TimeframeHours); err != nil {
+			return err
+		}
+	}
+	mu.Lock()
+	if cfg.Settings != nil {
+		s := *cfg.Settings
+		settings = &s
+	}
+	settingsManaged = cfg.Settings != nil
+	telegramConfig = cfg.Notifications.Telegram
+	mu.Unlock()
+
+	ResetMonitorLoop()
+	return nil
+}
+
+// applyConfigFile applies cfg, read from path, and records what it changed
+// in the audit log.
+func applyConfigFile(path string, cfg *Config) error {
+	before := configSnapshot()
+	if err := applyConfig(cfg); err != nil {
+		return err
+	}
+	after := configSnapshot()
+	b, _ := json.Marshal(before)
+	a, _ := json.Marshal(after)
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	recordAudit(storage.AuditEntry{
+		At:       clock.Now(),
+		Actor:    "config file",
+		Method:   "APPLY",
+		Path:     path,
+		Status:   http.StatusOK,
+		Resource: "config",
+	}, before, after)
+	return nil
+}
+
+// watchConfig applies the config file whenever its contents change.
+func watchConfig(path string, last []byte) {
+	for {
+		<-clock.After(configPollInterval)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			log.Println("config:", err)
+			continue
+		}
+		if bytes.Equal(b, last) {
+			continue
+		}
+		last = b
+		cfg, err := parseConfig(b)
+		if err != nil {
+			log.Println(err, "- keeping previous config")
+			continue
+		}
+		if err := applyConfigFile(path, cfg); err != nil {
+			log.Println("config: apply:", err)
+			continue
+		}
+		log.Println("config: reloaded", path)
+	}
+}
+
+// startConfig applies the config file at path and watches it for changes.
+func startConfig(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := parseConfig(b)
+	if err != nil {
+		return err
+	}
+	if err := applyConfigFile(path, cfg); err != nil {
+		return err
+	}
+	go watchConfig(path, b)
+	return nil
+}
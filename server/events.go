@@ -0,0 +1,221 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"uptime/probes"
+	"uptime/storage"
+)
+
+// Live events are published by the monitor as it produces them and streamed
+// to dashboards as Server-Sent Events by GET /events. Event IDs are
+// "<epoch>-<seq>": seq counts events since the process started and epoch
+// tells processes apart, so a client reconnecting with Last-Event-ID gets
+// exactly what it missed, or a reset event if that is no longer known.
+
+const (
+	// eventBacklog is how many recent events are kept for reconnecting
+	// clients.
+	eventBacklog = 4096
+	// eventBuffer is how many events a slow client may fall behind before
+	// its stream is closed; it then reconnects and catches up from the
+	// backlog.
+	eventBuffer = 256
+	// eventKeepAlive is the interval of comments that keep idle streams
+	// open through proxies.
+	eventKeepAlive = 15 * time.Second
+)
+
+// CheckEvent is the data of a "check" event: a new check result.
+type CheckEvent struct {
+	TargetID   int    `json:"targetId"`
+	TargetName string `json:"targetName"`
+	CheckResponse
+}
+
+// StateEvent is the data of a "state" event: a target went up or down.
+type StateEvent struct {
+	TargetID   int                 `json:"targetId"`
+	TargetName string              `json:"targetName"`
+	State      storage.TargetState `json:"state"`
+}
+
+type liveEvent struct {
+	seq    uint64
+	name   string
+	target int
+	data   []byte
+}
+
+type eventHub struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	recent [eventBacklog]liveEvent // event seq is at seq % eventBacklog
+	subs   map[chan liveEvent]struct{}
+}
+
+var events = &eventHub{epoch: rand.Text()[:8], subs: make(map[chan liveEvent]struct{})}
+
+// publishCheck publishes a check result, and the target's new state if its
+// status changed.
+func publishCheck(t storage.MonitorTarget, res probes.Result, state storage.TargetState, changed bool) {
+	events.publish("check", t.ID, CheckEvent{TargetID: t.ID, TargetName: t.Name, CheckResponse: newCheckResponse(res)})
+	if changed {
+		events.publish("state", t.ID, StateEvent{TargetID: t.ID, TargetName: t.Name, State: state})
+	}
+}
+
+func (h *eventHub) publish(name string, target int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("event error:", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	ev := liveEvent{seq: h.seq, name: name, target: target, data: data}
+	h.recent[h.seq%eventBacklog] = ev
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscription is one client's stream. Events are sent on ch, which is
+// closed when the client falls too far behind or the hub is reset.
+type subscription struct {
+	ch    chan liveEvent
+	epoch string
+	// missed holds the events after the client's Last-Event-ID, and reset
+	// is set when those are no longer available.
+	missed []liveEvent
+	reset  bool
+	// last is the ID of the latest event at the time of subscribing.
+	last uint64
+}
+
+func (s *subscription) id(seq uint64) string {
+	return s.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func (h *eventHub) subscribe(lastID string) *subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub := &subscription{ch: make(chan liveEvent, eventBuffer), epoch: h.epoch, last: h.seq}
+	h.subs[sub.ch] = struct{}{}
+	if lastID == "" {
+		return sub
+	}
+	epoch, s, _ := strings.Cut(lastID, "-")
+	seq, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || epoch != h.epoch || seq > h.seq || h.seq-seq > eventBacklog {
+		sub.reset = true
+		return sub
+	}
+	for seq++; seq <= h.seq; seq++ {
+		sub.missed = append(sub.missed, h.recent[seq%eventBacklog])
+	}
+	return sub
+}
+
+func (h *eventHub) unsubscribe(ch chan liveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// reset closes every stream and starts a new epoch.
+func (h *eventHub) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+	h.epoch = rand.Text()[:8]
+	h.seq = 0
+}
+
+// handleEvents serves GET /events, an event stream of "check" and "state"
+// events, optionally limited to the comma-separated target IDs in targets.
+// A client that reconnects with a Last-Event-ID the server no longer knows
+// first gets a "reset" event and should reload the data it displays.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	var filter map[int]bool
+	if s := r.URL.Query().Get("targets"); s != "" {
+		filter = make(map[int]bool)
+		for _, f := range strings.Split(s, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				http.Error(w, "invalid targets", http.StatusBadRequest)
+				return
+			}
+			filter[id] = true
+		}
+	}
+
+	sub := events.subscribe(r.Header.Get("Last-Event-ID"))
+	defer events.unsubscribe(sub.ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if sub.reset {
+		fmt.Fprintf(w, "event: reset\nid: %s\ndata: {}\n\n", sub.id(sub.last))
+	}
+	for _, ev := range sub.missed {
+		writeEvent(w, sub, ev, filter)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, sub, ev, filter)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes ev. Events of targets excluded by filter are reduced
+// to their ID, which browsers record without dispatching an event, so a
+// filtered client reconnects from its real position in the stream.
+func writeEvent(w http.ResponseWriter, sub *subscription, ev liveEvent, filter map[int]bool) {
+	if filter != nil && !filter[ev.target] {
+		fmt.Fprintf(w, "id: %s\n\n", sub.id(ev.seq))
+		return
+	}
+	fmt.Fprintf(w, "event: %s\nid: %s\ndata: %s\n\n", ev.name, sub.id(ev.seq), ev.data)
+}
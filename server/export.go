@@ -0,0 +1,423 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"uptime/probes"
+	"uptime/secrets"
+	"uptime/storage"
+)
+
+// exportVersion is the version of the export document format. Imports of
+// newer documents are rejected.
+const exportVersion = 1
+
+// ExportDocument is the document produced by /export and accepted by
+// /import.
+type ExportDocument struct {
+	Version       int                  `json:"version"`
+	ExportedAt    time.Time            `json:"exportedAt"`
+	Settings      *Settings            `json:"settings,omitempty"`
+	Notifications *ExportNotifications `json:"notifications,omitempty"`
+	Targets       []ExportTarget       `json:"targets"`
+	Checks        []ExportCheck        `json:"checks,omitempty"`
+}
+
+// ExportNotifications is the notifier configuration of an export. Secrets
+// are empty when redacted, which keeps the stored value on import.
+type ExportNotifications struct {
+	Telegram *TelegramConfig `json:"telegram,omitempty"`
+}
+
+type ExportTarget struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Type       string `json:"type"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	Subscribed bool   `json:"subscribed"`
+}
+
+type ExportCheck struct {
+	TargetName string `json:"targetName"`
+	CheckResponse
+}
+
+// handleExport serves GET /export. Passwords and notifier credentials are
+// redacted unless credentials=include; secret references are always exported
+// since they are not secret themselves. history=true streams check history as
+// well, optionally limited by from/to.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	includeCredentials := query.Get("credentials") == "include"
+	withHistory := query.Get("history") == "true"
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := storage.GetTargetRecords()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	mu.RLock()
+	s := *settings
+	mu.RUnlock()
+
+	doc := ExportDocument{
+		Version:    exportVersion,
+		ExportedAt: clock.Now().UTC(),
+		Settings:   &s,
+		Targets:    make([]ExportTarget, len(records)),
+	}
+	names := make(map[int]string, len(records))
+	for i, t := range records {
+		doc.Targets[i] = ExportTarget{Name: t.Name, URL: t.URL, Type: t.Type, Username: t.Username, Subscribed: t.Subscribed}
+		if includeCredentials || secrets.IsRef(t.Password) {
+			doc.Targets[i].Password = t.Password
+		}
+		names[t.ID] = t.Name
+	}
+	if cfg, _ := currentTelegramConfig(); cfg != nil {
+		redact := func(v string) string {
+			if includeCredentials || secrets.IsRef(v) {
+				return v
+			}
+			return ""
+		}
+		doc.Notifications = &ExportNotifications{Telegram: &TelegramConfig{BotToken: redact(cfg.BotToken), ChatID: redact(cfg.ChatID)}}
+	}
+
+	header, err := json.Marshal(doc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="uptime-export-%s.json"`, doc.ExportedAt.Format("20060102T150405Z")))
+	if !withHistory {
+		w.Write(header)
+		return
+	}
+
+	// Stream the history so large exports are never held in memory: the
+	// marshalled document is reopened and the checks array appended row by
+	// row.
+	w.Write(header[:len(header)-1])
+	w.Write([]byte(`,"checks":[`))
+	first := true
+	err = storage.StreamChecks(0, from, to, func(c storage.CheckRecord) error {
+		name, ok := names[c.TargetID]
+		if !ok {
+			return nil
+		}
+		b, err := json.Marshal(ExportCheck{TargetName: name, CheckResponse: newCheckResponse(c.Result)})
+		if err != nil {
+			return err
+		}
+		if !first {
+			w.Write([]byte(","))
+		}
+		first = false
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		// Headers are gone; the truncated document will fail to parse.
+		return
+	}
+	w.Write([]byte("]}"))
+}
+
+// ImportResult describes the changes an import made, or would make when
+// dryRun is set.
+type ImportResult struct {
+	DryRun    bool          `json:"dryRun"`
+	Mode      string        `json:"mode"`
+	Created   []string      `json:"created"`
+	Updated   []string      `json:"updated"`
+	Deleted   []string      `json:"deleted"`
+	Unchanged []string      `json:"unchanged"`
+	Settings  *SettingsDiff `json:"settings,omitempty"`
+	// Telegram reports whether the Telegram notifier changes.
+	Telegram bool `json:"telegram,omitempty"`
+	Checks   int  `json:"checks"`
+}
+
+type SettingsDiff struct {
+	From Settings `json:"from"`
+	To   Settings `json:"to"`
+}
+
+// handleImport serves POST /import?mode=merge|replace&dryRun=true. Targets
+// are matched by name. merge creates and updates targets; replace also
+// deletes targets missing from the document and, when the document has
+// history, replaces all existing history.
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		writeError(w, http.StatusBadRequest, "mode must be merge or replace")
+		return
+	}
+	dryRun := query.Get("dryRun") == "true"
+
+	var doc ExportDocument
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if doc.Version < 1 || doc.Version > exportVersion {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported export version %d", doc.Version))
+		return
+	}
+
+	existing, err := storage.GetTargetRecords()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	plan, result, fields := planImport(doc, existing, mode == "replace")
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid import", fields...)
+		return
+	}
+	result.DryRun = dryRun
+	result.Mode = mode
+
+	if !dryRun {
+		if err := storage.ApplyImport(plan, clock.Now()); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if plan.Settings != nil {
+			mu.Lock()
+			settings = &Settings{Frequency: plan.Settings.Frequency, TimeframeHours: plan.Settings.TimeframeHours}
+			mu.Unlock()
+		}
+		if plan.Telegram != nil {
+			loadSettings()
+		}
+		ResetMonitorLoop()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// planImport works out the changes doc makes to the existing targets and
+// settings. The document is rejected as a whole if any part of it is
+// invalid; the errors name fields as in targets[2].url.
+func planImport(doc ExportDocument, existing []storage.TargetRecord, replace bool) (storage.ImportPlan, *ImportResult, []FieldError) {
+	plan := storage.ImportPlan{Update: map[int]storage.TargetSpec{}}
+	result := &ImportResult{Created: []string{}, Updated: []string{}, Deleted: []string{}, Unchanged: []string{}}
+
+	byName := make(map[string]storage.TargetRecord, len(existing))
+	for _, t := range existing {
+		if _, ok := byName[t.Name]; !ok {
+			byName[t.Name] = t
+		}
+	}
+
+	var fields []FieldError
+	seen := make(map[string]bool, len(doc.Targets))
+	for i, t := range doc.Targets {
+		prefix := fmt.Sprintf("targets[%d].", i)
+		errs := validateTargetSpec(t.Name, t.URL, t.Type)
+		if len(errs) == 0 && seen[t.Name] {
+			errs = append(errs, FieldError{"name", "is used by another target of the document"})
+		}
+		for _, e := range errs {
+			fields = append(fields, FieldError{prefix + e.Field, e.Message})
+		}
+		if len(errs) > 0 {
+			continue
+		}
+		seen[t.Name] = true
+
+		spec := storage.TargetSpec{Name: t.Name, URL: t.URL, Type: t.Type, Username: t.Username, Password: t.Password, Subscribed: t.Subscribed}
+		cur, ok := byName[t.Name]
+		if !ok {
+			plan.Create = append(plan.Create, spec)
+			result.Created = append(result.Created, t.Name)
+			continue
+		}
+		if cur.Managed {
+			// Config-managed targets are owned by the config file.
+			result.Unchanged = append(result.Unchanged, t.Name)
+			continue
+		}
+		if cur.URL == t.URL && cur.Type == t.Type && cur.Username == t.Username && cur.Subscribed == t.Subscribed &&
+			(t.Password == "" || t.Password == cur.Password) {
+			result.Unchanged = append(result.Unchanged, t.Name)
+			continue
+		}
+		plan.Update[cur.ID] = spec
+		result.Updated = append(result.Updated, t.Name)
+	}
+
+	if replace {
+		for _, t := range existing {
+			if !seen[t.Name] && !t.Managed {
+				plan.Delete = append(plan.Delete, t.ID)
+				result.Deleted = append(result.Deleted, t.Name)
+			}
+		}
+	}
+
+	mu.RLock()
+	managed := settingsManaged
+	mu.RUnlock()
+	if doc.Settings != nil && !managed {
+		if doc.Settings.Frequency <= 0 {
+			fields = append(fields, FieldError{"settings.frequency", "must be positive"})
+		}
+		if doc.Settings.TimeframeHours <= 0 {
+			fields = append(fields, FieldError{"settings.timeframeHours", "must be positive"})
+		}
+		mu.RLock()
+		cur := *settings
+		mu.RUnlock()
+		if cur != *doc.Settings {
+			plan.Settings = &storage.Settings{Frequency: doc.Settings.Frequency, TimeframeHours: doc.Settings.TimeframeHours}
+			result.Settings = &SettingsDiff{From: cur, To: *doc.Settings}
+		}
+	}
+
+	// A notifier declared in the config file wins over imported ones.
+	if t := doc.Notifications; t != nil && t.Telegram != nil {
+		if cur, managed := currentTelegramConfig(); !managed {
+			if cur == nil {
+				cur = &TelegramConfig{}
+			}
+			if (t.Telegram.BotToken != "" && t.Telegram.BotToken != cur.BotToken) || (t.Telegram.ChatID != "" && t.Telegram.ChatID != cur.ChatID) {
+				plan.Telegram = &storage.TelegramSettings{BotToken: t.Telegram.BotToken, ChatID: t.Telegram.ChatID}
+				result.Telegram = true
+			}
+		}
+	}
+
+	if len(doc.Checks) > 0 {
+		plan.ClearChecks = replace
+		for _, c := range doc.Checks {
+			if _, ok := byName[c.TargetName]; !seen[c.TargetName] && (replace || !ok) {
+				continue
+			}
+			plan.Checks = append(plan.Checks, storage.ImportedCheck{
+				TargetName: c.TargetName,
+				CheckRecord: storage.CheckRecord{Result: probes.Result{
+					Target:    c.Target,
+					Type:      c.Type,
+					Status:    c.Status,
+					Duration:  time.Duration(c.Duration) * time.Millisecond,
+					CheckedAt: c.CheckedAt,
+					Message:   c.Message,
+				}},
+			})
+		}
+		result.Checks = len(plan.Checks)
+	}
+	return plan, result, fields
+}
+
+// CheckRow is one line of an NDJSON check export.
+type CheckRow struct {
+	TargetID   int    `json:"targetId"`
+	TargetName string `json:"targetName"`
+	CheckResponse
+}
+
+var checkExportColumns = []string{"id", "target_id", "target_name", "target", "type", "status", "duration_ms", "checked_at", "message"}
+
+// handleChecksExport serves GET /checks/export, streaming raw check rows
+// oldest first as CSV (the default) or NDJSON (format=ndjson). Optional
+// target (ID) and from/to (RFC 3339) narrow the rows. Timestamps are RFC 3339
+// in UTC with sub-second precision.
+func handleChecksExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	var targetID int
+	var err error
+	if s := query.Get("target"); s != "" {
+		if targetID, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "invalid target", http.StatusBadRequest)
+			return
+		}
+	}
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Resolve names up front: the rows stay open while streaming.
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	names := make(map[int]string, len(targets))
+	for _, t := range targets {
+		names[t.ID] = t.Name
+	}
+
+	// Errors while streaming cannot be reported once the headers are out;
+	// the client sees a truncated file.
+	filename := "checks-" + clock.Now().UTC().Format("20060102T150405Z") + "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		storage.StreamChecks(targetID, from, to, func(c storage.CheckRecord) error {
+			row := CheckRow{TargetID: c.TargetID, TargetName: names[c.TargetID], CheckResponse: newCheckResponse(c.Result)}
+			row.ID = c.ID
+			row.CheckedAt = row.CheckedAt.UTC()
+			return enc.Encode(row)
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write(checkExportColumns)
+	storage.StreamChecks(targetID, from, to, func(c storage.CheckRecord) error {
+		return cw.Write([]string{
+			strconv.FormatInt(c.ID, 10),
+			strconv.Itoa(c.TargetID),
+			names[c.TargetID],
+			c.Target,
+			c.Type,
+			strconv.FormatBool(c.Status),
+			strconv.FormatInt(c.Duration.Milliseconds(), 10),
+			c.CheckedAt.UTC().Format(time.RFC3339Nano),
+			c.Message,
+		})
+	})
+	cw.Flush()
+}
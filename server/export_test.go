@@ -0,0 +1,96 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"uptime/server"
+	"uptime/server/servertest"
+)
+
+func TestImportAppliesTelegramNotifier(t *testing.T) {
+	h := servertest.New(t)
+	importDoc := func(doc string) server.ImportResult {
+		t.Helper()
+		resp, err := h.Client.Post(h.Server.URL+"/api/import", "application/json", strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var res server.ImportResult
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&res) != nil {
+			t.Fatalf("POST /api/import: %s", resp.Status)
+		}
+		return res
+	}
+	telegram := func(query string) server.TelegramConfig {
+		t.Helper()
+		code, body := get(t, h.Client, h.Server.URL+"/api/export"+query)
+		var doc server.ExportDocument
+		if code != http.StatusOK || json.Unmarshal([]byte(body), &doc) != nil {
+			t.Fatalf("GET /api/export%s: %d", query, code)
+		}
+		if doc.Notifications == nil || doc.Notifications.Telegram == nil {
+			t.Fatalf("GET /api/export%s: no Telegram notifier in %s", query, body)
+		}
+		return *doc.Notifications.Telegram
+	}
+
+	res := importDoc(`{"version": 1, "targets": [], "notifications": {"telegram": {"botToken": "123:abc", "chatId": "env:UPTIME_CHAT_ID"}}}`)
+	if !res.Telegram {
+		t.Fatal("import did not report the Telegram notifier as changed")
+	}
+	// Secret references are exported even when credentials are redacted.
+	want := server.TelegramConfig{ChatID: "env:UPTIME_CHAT_ID"}
+	if got := telegram(""); got != want {
+		t.Fatalf("redacted export: %+v, want %+v", got, want)
+	}
+	want.BotToken = "123:abc"
+	if got := telegram("?credentials=include"); got != want {
+		t.Fatalf("export with credentials: %+v, want %+v", got, want)
+	}
+
+	// A redacted export imported back keeps the stored token.
+	if res := importDoc(`{"version": 1, "targets": [], "notifications": {"telegram": {"chatId": "env:UPTIME_CHAT_ID"}}}`); res.Telegram {
+		t.Fatal("redacted notifier reported as changed")
+	}
+	h.Restart()
+	if got := telegram("?credentials=include"); got != want {
+		t.Fatalf("export after a restart: %+v, want %+v", got, want)
+	}
+}
+
+func TestImportRejectsInvalidTargets(t *testing.T) {
+	h := servertest.New(t)
+	doc := `{"version": 1, "targets": [
+		{"name": "ok", "url": "https://ok.example", "type": "http"},
+		{"name": "ftp", "url": "ftp://files.example", "type": "ftp"},
+		{"name": "web", "url": "not a url", "type": "http"}]}`
+	resp, err := h.Client.Post(h.Server.URL+"/api/import", "application/json", strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var apiErr server.APIError
+	if resp.StatusCode != http.StatusBadRequest || json.NewDecoder(resp.Body).Decode(&apiErr) != nil {
+		t.Fatalf("import of invalid targets: %s, want 400", resp.Status)
+	}
+	var fields []string
+	for _, f := range apiErr.Fields {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"targets[1].type", "targets[2].url"}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("rejected fields %q, want %q", fields, want)
+	}
+
+	// The valid target was not imported either, and the monitor still runs.
+	h.Tick(time.Minute)
+	code, body := get(t, h.Client, h.Server.URL+"/api/targets")
+	if code != http.StatusOK || body != "null" {
+		t.Fatalf("targets after a rejected import: %d %s", code, body)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"uptime/storage"
+)
+
+// IncidentPatch is the body of PATCH /incidents/{id}; absent fields are
+// unchanged.
+type IncidentPatch struct {
+	Notes     *string `json:"notes,omitempty"`
+	RootCause *string `json:"rootCause,omitempty"`
+}
+
+// handleIncidents serves GET /incidents with optional target (ID), from/to
+// (RFC 3339, incidents overlapping the window), open=true and limit query
+// parameters.
+func handleIncidents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var q storage.IncidentQuery
+	var err error
+	if s := query.Get("target"); s != "" {
+		if q.TargetID, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "invalid target", http.StatusBadRequest)
+			return
+		}
+	}
+	if q.From, err = parseTimeParam(query.Get("from")); err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.To, err = parseTimeParam(query.Get("to")); err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.OpenOnly = query.Get("open") == "true"
+	if s := query.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	incidents, err := storage.ListIncidents(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+// handleIncident serves GET and PATCH /incidents/{id}. PATCH accepts
+// {"notes": "...", "rootCause": "..."}; omitted fields are left unchanged.
+func handleIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	inc, err := storage.GetIncident(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "incident not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var body IncidentPatch
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Notes != nil {
+			inc.Notes = *body.Notes
+		}
+		if body.RootCause != nil {
+			inc.RootCause = *body.RootCause
+		}
+		if err := storage.AnnotateIncident(id, inc.Notes, inc.RootCause); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inc)
+}
@@ -0,0 +1,39 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"uptime/server/servertest"
+	"uptime/storage"
+)
+
+func TestDeletingTargetClosesIncident(t *testing.T) {
+	h := servertest.New(t)
+	id := h.AddTarget("api", "stub://api", false)
+	h.SetResult("stub://api", false, "connection refused")
+	h.Tick(time.Minute)
+	h.Tick(10 * time.Minute)
+
+	req, _ := http.NewRequest(http.MethodDelete, h.Server.URL+"/api/targets/"+strconv.Itoa(id), nil)
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE target: %s", resp.Status)
+	}
+
+	code, body := get(t, h.Client, h.Server.URL+"/api/incidents/1")
+	var inc storage.Incident
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &inc) != nil {
+		t.Fatalf("GET incident: %d %s", code, body)
+	}
+	if inc.EndedAt == nil || !inc.EndedAt.Equal(h.Clock.Now()) {
+		t.Fatalf("incident of the deleted target ended at %v, want %v", inc.EndedAt, h.Clock.Now())
+	}
+}
@@ -1,74 +1,368 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
 	"time"
 
+	"uptime/probes"
 	"uptime/storage"
 )
 
 var (
-	once             sync.Once
 	monitorResetChan = make(chan struct{}, 1)
-	resourceStatus = make(map[string]bool)
+	// reportRequests wakes the report loop after each monitor pass.
+	reportRequests = make(chan struct{}, 1)
+	// states holds the current state of each target by ID; nil until
+	// loaded from storage.
+	states      map[int]*storage.TargetState
 	statusMutex sync.Mutex
+	// openIncidents caches which targets have an open incident; nil until
+	// loaded from storage on the first pass.
+	openIncidents map[int]bool
 )
 
+// The monitor loop's lifecycle. loopBusy is false while the loop waits for
+// its next pass, which is due at loopNext; ResetMonitorLoop sets it under
+// loopMu so MonitorIdle never misses a pending reset. reportsBusy is set the
+// same way while the report loop has work.
+var (
+	loopMu      sync.Mutex
+	loopIdle    = sync.NewCond(&loopMu)
+	loopStop    chan struct{}
+	loopDone    chan struct{}
+	loopBusy    bool
+	loopNext    time.Time
+	reportsBusy bool
+)
+
+// errPaused is returned by checkTarget for paused targets.
+var errPaused = errors.New("target is paused")
+
 // ResetMonitorLoop sends a signal to reset the monitor loop, breaking any current sleep.
 func ResetMonitorLoop() {
+	loopMu.Lock()
+	defer loopMu.Unlock()
 	select {
 	case monitorResetChan <- struct{}{}:
+		loopBusy = true
 	default:
 		// Channel is full, a reset is already pending.
 	}
 }
 
+// StartMonitoring loads the persisted target state and starts the monitor
+// loop unless it is already running.
 func StartMonitoring() {
-	once.Do(func() {
-		go monitorLoop()
-	})
+	loopMu.Lock()
+	defer loopMu.Unlock()
+	if loopStop != nil {
+		return
+	}
+	statusMutex.Lock()
+	if err := loadStates(); err != nil {
+		log.Println("error loading target state:", err)
+	}
+	statusMutex.Unlock()
+	loopStop, loopDone = make(chan struct{}), make(chan struct{})
+	loopBusy = true
+	go monitorLoop(loopStop, loopDone)
+}
+
+// StopMonitoring stops the monitor loop and waits for a running pass to
+// finish. The loop can be started again with StartMonitoring.
+func StopMonitoring() {
+	loopMu.Lock()
+	stop, done := loopStop, loopDone
+	loopStop, loopDone = nil, nil
+	loopMu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	select {
+	case <-monitorResetChan:
+	default:
+	}
+	select {
+	case <-reportRequests:
+	default:
+	}
+	loopMu.Lock()
+	loopBusy = false
+	reportsBusy = false
+	loopNext = time.Time{}
+	loopIdle.Broadcast()
+	loopMu.Unlock()
+}
+
+// MonitorIdle blocks until the monitor loop has finished its pending passes
+// and reports and waits for the next pass, and returns when that is due.
+// Tests use it to step the loop with a fake clock; it returns the zero time
+// if the loop is not running.
+func MonitorIdle() time.Time {
+	loopMu.Lock()
+	defer loopMu.Unlock()
+	for loopStop != nil && (loopBusy || reportsBusy || !clock.Now().Before(loopNext)) {
+		loopIdle.Wait()
+	}
+	return loopNext
+}
+
+// loadStates reads the persisted target state. statusMutex must be held.
+func loadStates() error {
+	stored, err := storage.GetTargetStates()
+	if err != nil {
+		return err
+	}
+	states = make(map[int]*storage.TargetState, len(stored))
+	for id, st := range stored {
+		states[id] = &st
+	}
+	return nil
 }
 
-func monitorLoop() {
+func monitorLoop(stop <-chan struct{}, done chan<- struct{}) {
+	reportsDone := make(chan struct{})
+	go reportLoop(stop, reportsDone)
+	defer func() {
+		<-reportsDone
+		close(done)
+	}()
 	for {
-		targets, err := storage.GetTargets()
-		if err != nil {
-			log.Println("error getting targets:", err)
-			time.Sleep(GetFrequency()) // Sleep even on error to prevent fast error loops
-			continue
+		if err := runPass(RunChecks); err != nil {
+			log.Println("monitor pass:", err)
 		}
+		requestReports()
 
-		freq := GetFrequency()
-		for _, t := range targets {
-			res := t.Probe.Check()
-			if err := storage.SaveCheck(res); err != nil {
-				log.Println("save error:", err)
-			}
-			if t.Subscribed {
-				currentStatus := res.Status
-				statusMutex.Lock()
-				previousStatus, ok := resourceStatus[t.Name]
-				if !ok {
-					previousStatus = true
-				}
-				if !currentStatus && previousStatus {
-					log.Printf("Resource '%s' is down, sending notification.", t.Name)
-					notifyDown(t.Name)
-				} else if currentStatus && !previousStatus {
-					log.Printf("Resource '%s' is back up, sending notification.", t.Name)
-					notifyUp(t.Name)
-				}
-
-				resourceStatus[t.Name] = currentStatus
-				statusMutex.Unlock()
-			}
+		frequency := GetFrequency()
+		wait := clock.After(frequency)
+		loopMu.Lock()
+		if len(monitorResetChan) == 0 {
+			loopBusy = false
+			loopNext = clock.Now().Add(frequency)
+			loopIdle.Broadcast()
 		}
+		loopMu.Unlock()
 
 		select {
-		case <-time.After(freq):
+		case <-wait:
 		case <-monitorResetChan:
 			// Settings have changed, loop immediately.
+		case <-stop:
+			return
+		}
+		loopMu.Lock()
+		loopBusy = true
+		loopMu.Unlock()
+	}
+}
+
+// runPass runs one pass of the monitor or report loop. A panic is logged
+// with its stack and ends only that pass: the loops must keep running, since
+// StopMonitoring and MonitorIdle wait for them.
+func runPass(pass func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return pass()
+}
+
+// requestReports wakes the report loop unless it already has a run pending.
+func requestReports() {
+	loopMu.Lock()
+	defer loopMu.Unlock()
+	select {
+	case reportRequests <- struct{}{}:
+		reportsBusy = true
+	default:
+	}
+}
+
+// reportLoop delivers due reports whenever the monitor loop asks for it, so
+// building and sending them never holds up a pass.
+func reportLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-reportRequests:
+		case <-stop:
+			return
+		}
+		if err := runPass(func() error { runReports(); return nil }); err != nil {
+			log.Println("reports:", err)
+		}
+		loopMu.Lock()
+		if len(reportRequests) == 0 {
+			reportsBusy = false
+			loopIdle.Broadcast()
+		}
+		loopMu.Unlock()
+	}
+}
+
+// RunChecks performs a single pass over all targets: it resumes targets
+// whose pause has run out, then runs each unpaused probe, stores the result,
+// publishes it to live event streams and sends notifications on status
+// transitions. Reports are delivered separately by the monitor loop.
+func RunChecks() error {
+	resumed, err := storage.ResumeDue(clock.Now())
+	if err != nil {
+		log.Println("resume error:", err)
+	}
+	for _, id := range resumed {
+		log.Printf("Target %d resumed", id)
+	}
+	targets, err := storage.GetTargets()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		if t.Paused {
+			continue
+		}
+		recordCheck(t, t.Probe.Check())
+	}
+	evaluateSLOs()
+	return nil
+}
+
+// recordCheck feeds a check result into the target's state, saves it,
+// tracks incidents and publishes it to live event streams.
+func recordCheck(t storage.MonitorTarget, res probes.Result) {
+	state, changed := updateState(t, res)
+	if err := storage.SaveTargetCheck(t.ID, res, &state); err != nil {
+		log.Println("save error:", err)
+	}
+	trackIncident(t, res)
+	publishCheck(t, res, state, changed)
+}
+
+// checkTarget runs the probe of target id outside the monitor loop and
+// records the result like a monitor pass does. A probe that takes longer
+// than timeout counts as failed; its late result is discarded. Paused
+// targets are not checked.
+func checkTarget(id int, timeout time.Duration) (probes.Result, error) {
+	t, err := storage.GetMonitorTarget(id)
+	if err != nil {
+		return probes.Result{}, err
+	}
+	if t.Paused {
+		return probes.Result{}, errPaused
+	}
+
+	done := make(chan probes.Result, 1)
+	go func() { done <- t.Probe.Check() }()
+	var res probes.Result
+	select {
+	case res = <-done:
+	case <-clock.After(timeout):
+		res = probes.Result{Target: t.URL, Type: t.Type, Duration: timeout, CheckedAt: clock.Now(),
+			Message: "check timed out after " + timeout.String()}
+	}
+	recordCheck(*t, res)
+	return res, nil
+}
+
+// updateState applies a check result to the target's state, sends
+// notifications for subscribed targets on status transitions and returns a
+// copy of the new state and whether the status changed.
+func updateState(t storage.MonitorTarget, res probes.Result) (storage.TargetState, bool) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	if states == nil {
+		if err := loadStates(); err != nil {
+			log.Println("error loading target state:", err)
+			states = make(map[int]*storage.TargetState)
 		}
 	}
+
+	st, ok := states[t.ID]
+	if !ok {
+		// Targets without history are assumed to be up.
+		st = &storage.TargetState{Up: true, Since: res.CheckedAt}
+		states[t.ID] = st
+	}
+	previousStatus := st.Up
+	currentStatus := res.Status
+	if currentStatus != previousStatus {
+		st.Up = currentStatus
+		st.Since = res.CheckedAt
+	}
+	if currentStatus {
+		st.ConsecutiveSuccesses++
+		st.ConsecutiveFailures = 0
+	} else {
+		st.ConsecutiveFailures++
+		st.ConsecutiveSuccesses = 0
+	}
+	st.LastCheckedAt = res.CheckedAt
+	st.LastMessage = res.Message
+
+	if t.Subscribed {
+		if !currentStatus && previousStatus {
+			log.Printf("Resource '%s' is down, sending notification.", t.Name)
+			notifyDown(t.Name, st)
+		} else if currentStatus && !previousStatus {
+			log.Printf("Resource '%s' is back up, sending notification.", t.Name)
+			notifyUp(t.Name, st)
+		}
+	}
+	return *st, currentStatus != previousStatus
+}
+
+// trackIncident opens an incident when a target starts failing and closes
+// it when the target recovers.
+func trackIncident(t storage.MonitorTarget, res probes.Result) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	if openIncidents == nil {
+		open, err := storage.OpenIncidentTargets()
+		if err != nil {
+			log.Println("incident error:", err)
+			return
+		}
+		openIncidents = open
+	}
+
+	if !res.Status {
+		opened, err := storage.OpenIncident(t.ID, t.Name, res.CheckedAt, res.Message)
+		if err != nil {
+			log.Println("incident error:", err)
+			return
+		}
+		if opened {
+			log.Printf("Incident opened for '%s': %s", t.Name, res.Message)
+		}
+		openIncidents[t.ID] = true
+		return
+	}
+	if openIncidents[t.ID] {
+		if _, err := storage.CloseIncident(t.ID, res.CheckedAt); err != nil {
+			log.Println("incident error:", err)
+			return
+		}
+		log.Printf("Incident closed for '%s'", t.Name)
+		delete(openIncidents, t.ID)
+	}
+}
+
+// ResetState drops the in-memory target state and incident cache so they
+// are reloaded from storage on the next pass, reloads the settings and
+// closes live event streams.
+func ResetState() {
+	loadSettings()
+	events.reset()
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	states = nil
+	openIncidents = nil
+	resetSLOEval()
 }
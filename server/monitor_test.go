@@ -0,0 +1,139 @@
+package server_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"uptime/probes"
+	"uptime/server/servertest"
+	"uptime/storage"
+)
+
+func TestMonitorLoopNotifiesTransitions(t *testing.T) {
+	h := servertest.New(t)
+	h.AddTarget("api", "stub://api", true)
+	h.SetResult("stub://api", false, "connection refused")
+
+	h.Tick(time.Minute)
+	h.Tick(time.Minute)
+	h.SetResult("stub://api", true, "")
+	h.Tick(time.Minute)
+
+	want := []string{"🚨 Resource down: api", "✅ Resource back up: api"}
+	if got := h.Messages(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+	n, err := storage.CountChecks("stub://api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("%d checks stored, want one per pass (3)", n)
+	}
+}
+
+func TestMonitorLoopFollowsFrequency(t *testing.T) {
+	h := servertest.New(t)
+	h.AddTarget("api", "stub://api", false)
+	h.Tick(0) // the pass run at start, before the target existed
+
+	resp, err := h.Client.Post(h.Server.URL+"/api/settings", "application/json",
+		strings.NewReader(`{"frequency": 10, "timeframeHours": 24}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update settings: %s", resp.Status)
+	}
+
+	// The change resets the loop, which checks right away and then every
+	// 10 seconds.
+	for _, step := range []struct {
+		d    time.Duration
+		want int
+	}{{0, 1}, {9 * time.Second, 1}, {time.Second, 2}, {30 * time.Second, 5}} {
+		h.Tick(step.d)
+		n, err := storage.CountChecks("stub://api")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != step.want {
+			t.Fatalf("after %v: %d checks, want %d", step.d, n, step.want)
+		}
+	}
+}
+
+func TestMonitorStateSurvivesRestart(t *testing.T) {
+	h := servertest.New(t)
+	h.AddTarget("api", "stub://api", true)
+	h.SetResult("stub://api", false, "timeout")
+	h.Tick(time.Minute)
+
+	// A restart must neither re-alert the target that is still down nor
+	// miss its recovery.
+	h.Restart()
+	h.Tick(time.Minute)
+	h.SetResult("stub://api", true, "")
+	h.Tick(time.Minute)
+
+	want := []string{"🚨 Resource down: api", "✅ Resource back up: api"}
+	if got := h.Messages(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestMonitorLoopFailsTargetsOfUnknownType(t *testing.T) {
+	h := servertest.New(t)
+	// A type whose probe is no longer registered, for example.
+	id, err := storage.AddTarget("files", "ftp://files.example", "ftp", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Tick(time.Minute)
+
+	checks, _, err := storage.QueryChecks(storage.CheckQuery{TargetID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Status || checks[0].Message != `unknown target type "ftp"` {
+		t.Fatalf("checks of a target of unknown type: %+v, want one failure", checks)
+	}
+}
+
+// panickingProbe panics on its first check and succeeds afterwards.
+type panickingProbe struct {
+	calls *atomic.Int32
+	clock *servertest.FakeClock
+}
+
+func (p panickingProbe) Check() probes.Result {
+	if p.calls.Add(1) == 1 {
+		panic("probe bug")
+	}
+	return probes.Result{Target: "flaky://api", Type: "flaky", Status: true, CheckedAt: p.clock.Now()}
+}
+
+func TestMonitorLoopSurvivesPanickingPass(t *testing.T) {
+	h := servertest.New(t)
+	var calls atomic.Int32
+	storage.RegisterProbe("flaky", func(string, string, string) probes.Target { return panickingProbe{&calls, h.Clock} })
+	if _, err := storage.AddTarget("flaky", "flaky://api", "flaky", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first pass panics; Tick used to hang as the loop never went idle.
+	h.Tick(time.Minute)
+	h.Tick(time.Minute)
+	n, err := storage.CountChecks("flaky://api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("%d checks stored after a panicking pass and a good one, want 1", n)
+	}
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"uptime/secrets"
+	"uptime/storage"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Single sign-on uses the OpenID Connect authorization code flow with PKCE.
+// GET /api/auth/oidc/login redirects the browser to the identity provider,
+// which sends it back to /api/auth/oidc/callback. There the code is
+// exchanged, the ID token verified and a regular session started for the
+// user linked to the token's subject.
+const (
+	oidcStateCookie = "uptime_oidc_state"
+	oidcLoginTTL    = 10 * time.Minute
+	oidcCallback    = "/api/auth/oidc/callback"
+	// oidcDiscoveryTimeout bounds fetching the provider's discovery document.
+	oidcDiscoveryTimeout = 10 * time.Second
+)
+
+// OIDCConfig configures single sign-on. GroupRoles maps the provider's group
+// names (read from GroupsClaim) to roles; a user in several mapped groups
+// gets the highest role. Users in no mapped group get DefaultRole, or are
+// refused if it is empty.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // may be a secret reference
+	// RedirectURL defaults to /api/auth/oidc/callback on the host the login
+	// was requested from.
+	RedirectURL string
+	GroupsClaim string // default "groups"
+	GroupRoles  map[string]string
+	DefaultRole string
+}
+
+// oidcLogin is a login started by /auth/oidc/login and not yet completed.
+type oidcLogin struct {
+	nonce       string
+	verifier    string
+	redirectURL string
+	expires     time.Time
+}
+
+var (
+	oidcMu       sync.Mutex
+	oidcConfig   *OIDCConfig
+	oidcProvider *oidc.Provider
+	oidcLogins   = map[string]oidcLogin{}
+)
+
+// SetOIDC enables single sign-on with cfg, or disables it if cfg is nil.
+// The provider is discovered on the first login.
+func SetOIDC(cfg *OIDCConfig) error {
+	if cfg != nil {
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return fmt.Errorf("oidc: issuer and client ID are required")
+		}
+		if cfg.DefaultRole != "" && !storage.IsRole(cfg.DefaultRole) {
+			return fmt.Errorf("oidc: unknown default role %q", cfg.DefaultRole)
+		}
+		for group, role := range cfg.GroupRoles {
+			if !storage.IsRole(role) {
+				return fmt.Errorf("oidc: unknown role %q for group %q", role, group)
+			}
+		}
+	}
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	oidcConfig = cfg
+	oidcProvider = nil
+	oidcLogins = map[string]oidcLogin{}
+	return nil
+}
+
+// configureOIDC enables single sign-on from OIDC_* environment variables.
+// It does nothing unless OIDC_ISSUER is set.
+func configureOIDC() error {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	cfg := &OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		GroupRoles:   map[string]string{},
+		DefaultRole:  os.Getenv("OIDC_DEFAULT_ROLE"),
+	}
+	for _, pair := range strings.Split(os.Getenv("OIDC_GROUP_ROLES"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("OIDC_GROUP_ROLES: expected group=role, got %q", pair)
+		}
+		cfg.GroupRoles[strings.TrimSpace(group)] = strings.TrimSpace(role)
+	}
+	if err := SetOIDC(cfg); err != nil {
+		return err
+	}
+	log.Printf("single sign-on enabled with %s", issuer)
+	return nil
+}
+
+// oidcClient returns the configuration and the OAuth2 client for a login,
+// discovering the provider if needed. cfg is nil if single sign-on is off.
+// Discovery runs without holding oidcMu, so a slow provider only delays the
+// logins waiting for it.
+func oidcClient(r *http.Request, redirectURL string) (*OIDCConfig, *oidc.Provider, *oauth2.Config, error) {
+	oidcMu.Lock()
+	cfg, provider := oidcConfig, oidcProvider
+	oidcMu.Unlock()
+	if cfg == nil {
+		return nil, nil, nil, nil
+	}
+	if provider == nil {
+		ctx, cancel := context.WithTimeout(r.Context(), oidcDiscoveryTimeout)
+		defer cancel()
+		p, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		provider = p
+		oidcMu.Lock()
+		// Keep the provider unless SetOIDC replaced the configuration
+		// meanwhile.
+		if oidcConfig == cfg && oidcProvider == nil {
+			oidcProvider = p
+		}
+		oidcMu.Unlock()
+	}
+	secret, err := secrets.Resolve(cfg.ClientSecret)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if redirectURL == "" {
+		redirectURL = cfg.RedirectURL
+	}
+	if redirectURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		redirectURL = scheme + "://" + r.Host + oidcCallback
+	}
+	return cfg, provider, &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: secret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}, nil
+}
+
+// AuthProviders tells the login page which login methods to offer.
+type AuthProviders struct {
+	OIDC bool `json:"oidc"`
+}
+
+// handleAuthProviders serves GET /auth/providers.
+func handleAuthProviders(w http.ResponseWriter, r *http.Request) {
+	oidcMu.Lock()
+	enabled := oidcConfig != nil
+	oidcMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuthProviders{OIDC: enabled})
+}
+
+// handleOIDCLogin serves GET /auth/oidc/login and redirects to the identity
+// provider.
+func handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	_, _, oauth, err := oidcClient(r, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if oauth == nil {
+		http.Error(w, "single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+	state := rand.Text()
+	login := oidcLogin{
+		nonce:       rand.Text(),
+		verifier:    oauth2.GenerateVerifier(),
+		redirectURL: oauth.RedirectURL,
+		expires:     clock.Now().Add(oidcLoginTTL),
+	}
+
+	oidcMu.Lock()
+	for s, l := range oidcLogins {
+		if !clock.Now().Before(l.expires) {
+			delete(oidcLogins, s)
+		}
+	}
+	oidcLogins[state] = login
+	oidcMu.Unlock()
+
+	// The cookie ties the state to this browser, so a callback URL cannot be
+	// used to log someone else in.
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: state, Path: oidcCallback,
+		MaxAge: int(oidcLoginTTL.Seconds()), HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+	redirect(w, oauth.AuthCodeURL(state, oidc.Nonce(login.nonce), oauth2.S256ChallengeOption(login.verifier)))
+}
+
+// handleOIDCCallback serves GET /auth/oidc/callback, starts a session for
+// the provider's user and redirects to the frontend.
+func handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		http.Error(w, "identity provider: "+e+" "+query.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+	state := query.Get("state")
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || c.Value != state {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCallback, MaxAge: -1})
+	oidcMu.Lock()
+	login, ok := oidcLogins[state]
+	delete(oidcLogins, state)
+	oidcMu.Unlock()
+	if !ok || !clock.Now().Before(login.expires) {
+		http.Error(w, "login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	cfg, provider, oauth, err := oidcClient(r, login.redirectURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if oauth == nil {
+		http.Error(w, "single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+	token, err := oauth.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		http.Error(w, "code exchange: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	rawID, _ := token.Extra("id_token").(string)
+	if rawID == "" {
+		http.Error(w, "no id_token in token response", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}).Verify(r.Context(), rawID)
+	if err != nil {
+		http.Error(w, "invalid id_token: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != login.nonce {
+		http.Error(w, "invalid id_token nonce", http.StatusUnauthorized)
+		return
+	}
+
+	username, role, err := oidcIdentity(cfg, idToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	user, err := storage.SyncOIDCUser(idToken.Issuer+" "+idToken.Subject, username, role, clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if _, err := startSession(w, r, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	redirect(w, "/")
+}
+
+// redirect sends a 302 to url. Unlike http.Redirect it writes no HTML body,
+// which the API does not document.
+func redirect(w http.ResponseWriter, url string) {
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusFound)
+}
+
+// oidcIdentity returns the username and role for an ID token. The username
+// is preferred_username, then email, then the subject.
+func oidcIdentity(cfg *OIDCConfig, idToken *oidc.IDToken) (username, role string, err error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return "", "", err
+	}
+	for _, name := range []string{"preferred_username", "email"} {
+		if s, ok := claims[name].(string); ok && s != "" {
+			username = s
+			break
+		}
+	}
+	if username == "" {
+		username = idToken.Subject
+	}
+
+	claim := cfg.GroupsClaim
+	if claim == "" {
+		claim = "groups"
+	}
+	var groups []string
+	switch v := claims[claim].(type) {
+	case string:
+		groups = []string{v}
+	case []any:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	for _, g := range groups {
+		if r, ok := cfg.GroupRoles[g]; ok && (role == "" || storage.RoleAtLeast(r, role)) {
+			role = r
+		}
+	}
+	if role == "" {
+		role = cfg.DefaultRole
+	}
+	if role == "" {
+		return "", "", errors.New("your account is not in a group that may use this instance")
+	}
+	return username, role, nil
+}
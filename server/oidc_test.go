@@ -0,0 +1,207 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"uptime/server"
+	"uptime/server/servertest"
+)
+
+func me(t *testing.T, h *servertest.Harness, c *http.Client) server.AuthResponse {
+	t.Helper()
+	resp, err := c.Get(h.Server.URL + "/api/auth/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var auth server.AuthResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&auth) != nil {
+		t.Fatalf("GET /api/auth/me: %s", resp.Status)
+	}
+	return auth
+}
+
+// startOIDCLogin runs a login up to the provider's redirect back to the
+// callback, which it returns unvisited.
+func startOIDCLogin(t *testing.T, h *servertest.Harness, c *http.Client) *url.URL {
+	t.Helper()
+	resp, err := c.Get(h.Server.URL + "/api/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	auth, err := resp.Location()
+	if err != nil {
+		t.Fatalf("login: %s without a redirect", resp.Status)
+	}
+	q := auth.Query()
+	if q.Get("state") == "" || q.Get("nonce") == "" || q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization request %s lacks state, nonce or an S256 PKCE challenge", auth)
+	}
+	if resp, err = c.Get(auth.String()); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("authorize: %s without a redirect", resp.Status)
+	}
+	return callback
+}
+
+func noRedirectClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+// get requests u and returns the status code and body.
+func get(t *testing.T, c *http.Client, u string) (int, string) {
+	t.Helper()
+	resp, err := c.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, strings.TrimSpace(string(body))
+}
+
+func TestOIDCGroupsMapToRoles(t *testing.T) {
+	h := servertest.New(t)
+	p := h.StartOIDC(map[string]string{"sre": "operator", "uptime-admins": "admin"}, "")
+
+	for _, tc := range []struct {
+		user servertest.OIDCUser
+		role string
+	}{
+		{servertest.OIDCUser{Subject: "1", Username: "alice", Groups: []string{"sre", "uptime-admins"}}, "admin"},
+		{servertest.OIDCUser{Subject: "2", Username: "bob", Groups: []string{"sre", "staff"}}, "operator"},
+		// A later login applies the provider's current groups.
+		{servertest.OIDCUser{Subject: "1", Username: "alice", Groups: []string{"sre"}}, "operator"},
+	} {
+		c, resp := p.Login(tc.user)
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("login as %s: %s", tc.user.Username, resp.Status)
+		}
+		if got := me(t, h, c).User; got.Username != tc.user.Username || got.Role != tc.role {
+			t.Fatalf("logged in as %s with groups %q: got %+v, want role %s", tc.user.Username, tc.user.Groups, got, tc.role)
+		}
+	}
+
+	// Without a default role, users in no mapped group are refused.
+	if _, resp := p.Login(servertest.OIDCUser{Subject: "3", Username: "carol", Groups: []string{"staff"}}); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("login without a mapped group: %s, want 403", resp.Status)
+	}
+}
+
+func TestOIDCDefaultRole(t *testing.T) {
+	h := servertest.New(t)
+	p := h.StartOIDC(map[string]string{"sre": "operator"}, "viewer")
+	c, resp := p.Login(servertest.OIDCUser{Subject: "1", Username: "dave"})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login: %s", resp.Status)
+	}
+	if role := me(t, h, c).User.Role; role != "viewer" {
+		t.Fatalf("role = %s, want the default viewer", role)
+	}
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+	h := servertest.New(t)
+	p := h.StartOIDC(nil, "viewer")
+	p.Login(servertest.OIDCUser{Subject: "1", Username: "erin"})
+
+	c := noRedirectClient()
+	callback := startOIDCLogin(t, h, c)
+
+	forged := *callback
+	q := forged.Query()
+	q.Set("state", "forged")
+	forged.RawQuery = q.Encode()
+	if code, body := get(t, c, forged.String()); code != http.StatusBadRequest || body != "invalid login state" {
+		t.Fatalf("callback with a forged state: %d %q", code, body)
+	}
+	// The state is bound to the browser that started the login.
+	if code, body := get(t, noRedirectClient(), callback.String()); code != http.StatusBadRequest || body != "invalid login state" {
+		t.Fatalf("callback from another browser: %d %q", code, body)
+	}
+	if code, body := get(t, c, callback.String()); code != http.StatusFound {
+		t.Fatalf("callback: %d %q, want 302", code, body)
+	}
+	// States are single use.
+	if code, _ := get(t, c, callback.String()); code != http.StatusBadRequest {
+		t.Fatalf("replayed callback: %d, want 400", code)
+	}
+}
+
+func TestOIDCLoginExpires(t *testing.T) {
+	h := servertest.New(t)
+	h.StartOIDC(nil, "viewer")
+	c := noRedirectClient()
+	callback := startOIDCLogin(t, h, c)
+	h.Tick(11 * time.Minute)
+	if code, body := get(t, c, callback.String()); code != http.StatusBadRequest || !strings.Contains(body, "expired") {
+		t.Fatalf("callback after 11 minutes: %d %q", code, body)
+	}
+}
+
+func TestOIDCCallbackChecksNonce(t *testing.T) {
+	h := servertest.New(t)
+	p := h.StartOIDC(nil, "viewer")
+	p.EditClaims = func(claims map[string]any) { claims["nonce"] = "replayed" }
+	c := noRedirectClient()
+	if code, body := get(t, c, startOIDCLogin(t, h, c).String()); code != http.StatusUnauthorized || body != "invalid id_token nonce" {
+		t.Fatalf("callback with a wrong nonce: %d %q", code, body)
+	}
+}
+
+func TestOIDCCodeNeedsItsPKCEVerifier(t *testing.T) {
+	h := servertest.New(t)
+	h.StartOIDC(nil, "viewer")
+	c := noRedirectClient()
+	first := startOIDCLogin(t, h, c)
+	second := startOIDCLogin(t, h, c)
+
+	// The code of the first login, redeemed with the second login's state
+	// and thus its verifier, is refused by the provider.
+	stolen := *second
+	q := stolen.Query()
+	q.Set("code", first.Query().Get("code"))
+	stolen.RawQuery = q.Encode()
+	if code, body := get(t, c, stolen.String()); code != http.StatusUnauthorized || !strings.Contains(body, "invalid_grant") {
+		t.Fatalf("callback with another login's code: %d %q", code, body)
+	}
+}
+
+func TestAuthProviders(t *testing.T) {
+	h := servertest.New(t)
+	providers := func() server.AuthProviders {
+		t.Helper()
+		resp, err := http.Get(h.Server.URL + "/api/auth/providers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var p server.AuthProviders
+		if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&p) != nil {
+			t.Fatalf("GET /api/auth/providers: %s", resp.Status)
+		}
+		return p
+	}
+	if providers().OIDC {
+		t.Fatal("single sign-on offered without a provider")
+	}
+	h.StartOIDC(nil, "viewer")
+	if !providers().OIDC {
+		t.Fatal("single sign-on not offered")
+	}
+}
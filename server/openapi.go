@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The OpenAPI document is derived from apiRoutes, and its schemas from the
+// Go types the handlers encode and decode, so it describes exactly what is
+// served. servertest checks every response of a test against it.
+
+// OpenAPI returns the OpenAPI 3.0 document served at /api/openapi.json.
+func OpenAPI() map[string]any {
+	schemas := &schemaSet{defs: map[string]any{}, types: map[string]reflect.Type{}}
+	paths := map[string]map[string]any{}
+	for _, rt := range apiRoutes() {
+		if paths[rt.Path] == nil {
+			paths[rt.Path] = map[string]any{}
+		}
+		paths[rt.Path][strings.ToLower(rt.Method)] = openAPIOperation(rt, schemas)
+	}
+	// Referenced by error responses and the data of /events respectively.
+	schemas.of(reflect.TypeOf(APIError{}))
+	schemas.of(reflect.TypeOf(CheckEvent{}))
+	schemas.of(reflect.TypeOf(StateEvent{}))
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Uptime Monitor API",
+			"version": "1",
+			"description": "Authenticate with an API token (Authorization: Bearer) or a session cookie from /auth/login. " +
+				"Session requests other than GET, HEAD and OPTIONS must send the session's CSRF token in X-CSRF-Token.",
+		},
+		"servers":  []any{map[string]any{"url": "/api"}},
+		"security": []any{map[string]any{"bearer": []any{}}, map[string]any{"session": []any{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": schemas.defs,
+			"securitySchemes": map[string]any{
+				"bearer":  map[string]any{"type": "http", "scheme": "bearer"},
+				"session": map[string]any{"type": "apiKey", "in": "cookie", "name": sessionCookie},
+			},
+		},
+	}
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OpenAPI())
+}
+
+func openAPIOperation(rt apiRoute, schemas *schemaSet) map[string]any {
+	op := map[string]any{"operationId": rt.ID, "summary": rt.Summary}
+	if rt.Role != "" {
+		op["description"] = "Requires the " + rt.Role + " role."
+		op["x-required-role"] = rt.Role
+	}
+	if rt.Public {
+		op["security"] = []any{}
+	}
+
+	var params []any
+	if strings.Contains(rt.Path, "{id}") {
+		params = append(params, map[string]any{"name": "id", "in": "path", "required": true,
+			"schema": map[string]any{"type": "integer", "format": "int64"}})
+	}
+	for _, p := range rt.Query {
+		s := map[string]any{"type": p.Type}
+		switch p.Type {
+		case "date-time":
+			s = map[string]any{"type": "string", "format": "date-time"}
+		case "duration":
+			s = map[string]any{"type": "string", "format": "duration"}
+		}
+		if len(p.Enum) > 0 {
+			s["enum"] = p.Enum
+		}
+		params = append(params, map[string]any{"name": p.Name, "in": "query", "description": p.Description, "schema": s})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if rt.Body != nil {
+		op["requestBody"] = map[string]any{"required": true, "content": map[string]any{
+			"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(rt.Body))},
+		}}
+	}
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case rt.Response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(rt.Response))}}
+	case len(rt.Produces) > 0:
+		content := map[string]any{}
+		for _, typ := range rt.Produces {
+			content[typ] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		success["content"] = content
+	}
+	failure := map[string]any{"description": "Error", "content": map[string]any{
+		"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+	}}
+	if rt.JSONErrors {
+		failure["content"] = map[string]any{"application/json": map[string]any{"schema": schemaRef("APIError")}}
+	}
+	responses := map[string]any{strconv.Itoa(status): success, "default": failure}
+	if !rt.Public {
+		// requireAuth and allow answer in plain text on every route.
+		plain := map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}
+		responses["401"] = map[string]any{"description": "Not logged in", "content": plain}
+		responses["403"] = map[string]any{"description": "Role, token scope or CSRF token insufficient", "content": plain}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// schemaSet builds JSON schemas for Go types the way encoding/json encodes
+// them. Named structs become components, which are named after the Go type
+// and must therefore be unique across packages.
+type schemaSet struct {
+	defs  map[string]any
+	types map[string]reflect.Type
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (s *schemaSet) of(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := s.of(t.Elem())
+		if _, ok := elem["$ref"]; ok {
+			// Siblings of $ref are ignored in OpenAPI 3.0.
+			return map[string]any{"allOf": []any{elem}, "nullable": true}
+		}
+		elem["nullable"] = true
+		return elem
+	case reflect.Slice:
+		// encoding/json writes nil slices as null.
+		return map[string]any{"type": "array", "items": s.of(t.Elem()), "nullable": true}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if prev, ok := s.types[t.Name()]; ok {
+			if prev != t {
+				panic(fmt.Sprintf("openapi: schema name %s used by %s and %s", t.Name(), prev, t))
+			}
+		} else {
+			s.types[t.Name()] = t
+			s.defs[t.Name()] = s.object(t)
+		}
+		return schemaRef(t.Name())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
+
+// object describes a struct. Fields of embedded structs are promoted, and
+// fields without omitempty are required. x-go-name keeps the Go field name
+// for the client generator.
+func (s *schemaSet) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" || !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				add(f.Type)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			prop := s.of(f.Type)
+			prop["x-go-name"] = f.Name
+			props[name] = prop
+			if !strings.Contains(","+opts+",", ",omitempty,") {
+				required = append(required, name)
+			}
+		}
+	}
+	add(t)
+	obj := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"uptime/storage"
+)
+
+// PauseRequest is the body of POST /targets/{id}/pause, which may be
+// omitted. ResumeIn is a duration such as "2h" or "1d" and takes precedence
+// over ResumeAt; without either the target stays paused until it is resumed.
+type PauseRequest struct {
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
+	ResumeIn string     `json:"resumeIn,omitempty"`
+}
+
+// BulkPauseRequest is the body of POST /targets/pause.
+type BulkPauseRequest struct {
+	IDs []int `json:"ids"`
+	PauseRequest
+}
+
+// BulkResumeRequest is the body of POST /targets/resume.
+type BulkResumeRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// resumeTime returns when a pause requested at now ends, or nil if it does
+// not end by itself.
+func (p PauseRequest) resumeTime(now time.Time) (*time.Time, []FieldError) {
+	if p.ResumeIn != "" {
+		d, err := parseDays(p.ResumeIn)
+		if err != nil || d <= 0 {
+			return nil, []FieldError{{"resumeIn", "must be a positive duration such as 2h or 1d"}}
+		}
+		at := now.Add(d).UTC()
+		return &at, nil
+	}
+	if p.ResumeAt != nil && !p.ResumeAt.After(now) {
+		return nil, []FieldError{{"resumeAt", "must be in the future"}}
+	}
+	if p.ResumeAt == nil {
+		return nil, nil
+	}
+	at := p.ResumeAt.UTC()
+	return &at, nil
+}
+
+// handlePauseTarget serves POST /targets/{id}/pause. Pausing a paused
+// target changes when it resumes.
+func handlePauseTarget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var body PauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := clock.Now()
+	resumeAt, fields := body.resumeTime(now)
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid pause", fields...)
+		return
+	}
+	if err := storage.PauseTarget(id, now, resumeAt); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	writeTarget(w, http.StatusOK, id)
+}
+
+// handleResumeTarget serves POST /targets/{id}/resume. Resuming a target
+// that is not paused does nothing.
+func handleResumeTarget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	resumed, err := storage.ResumeTarget(id, clock.Now())
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	if resumed {
+		ResetMonitorLoop() // check it right away
+	}
+	writeTarget(w, http.StatusOK, id)
+}
+
+// handleBulkPause serves POST /targets/pause, which pauses every target in
+// ids until the same time.
+func handleBulkPause(w http.ResponseWriter, r *http.Request) {
+	var body BulkPauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := clock.Now()
+	resumeAt, fields := body.resumeTime(now)
+	idFields, err := checkTargetIDs(body.IDs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if fields = append(fields, idFields...); len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid pause", fields...)
+		return
+	}
+	for _, id := range body.IDs {
+		if err := storage.PauseTarget(id, now, resumeAt); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+	}
+	writeTargets(w, body.IDs)
+}
+
+// handleBulkResume serves POST /targets/resume.
+func handleBulkResume(w http.ResponseWriter, r *http.Request) {
+	var body BulkResumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := checkTargetIDs(body.IDs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(fields) > 0 {
+		writeError(w, http.StatusBadRequest, "invalid resume", fields...)
+		return
+	}
+	now := clock.Now()
+	for _, id := range body.IDs {
+		if _, err := storage.ResumeTarget(id, now); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+	}
+	ResetMonitorLoop()
+	writeTargets(w, body.IDs)
+}
+
+// checkTargetIDs rejects an empty list of target IDs or one naming a target
+// that does not exist, so a bulk request changes either all targets or none.
+func checkTargetIDs(ids []int) ([]FieldError, error) {
+	if len(ids) == 0 {
+		return []FieldError{{"ids", "is required"}}, nil
+	}
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int]bool, len(targets))
+	for _, t := range targets {
+		known[t.ID] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return []FieldError{{"ids", "target " + strconv.Itoa(id) + " does not exist"}}, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeTargets responds with the current state of the targets in ids, in
+// that order.
+func writeTargets(w http.ResponseWriter, ids []int) {
+	infos, err := storage.GetTargetInfos()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	byID := make(map[int]storage.TargetInfo, len(infos))
+	for _, t := range infos {
+		byID[t.ID] = t
+	}
+	targets := make([]storage.TargetInfo, 0, len(ids))
+	for _, id := range ids {
+		if t, ok := byID[id]; ok {
+			targets = append(targets, t)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targets)
+}
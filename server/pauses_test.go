@@ -0,0 +1,39 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"uptime/server/servertest"
+	"uptime/storage"
+)
+
+func TestPauseResumesAtTimeInAnyZone(t *testing.T) {
+	h := servertest.New(t)
+	id := h.AddTarget("api", "stub://api", false)
+
+	// An hour from now, written with an offset that sorts after UTC as text.
+	resumeAt := h.Clock.Now().Add(time.Hour).In(time.FixedZone("", 5*3600)).Format(time.RFC3339)
+	post(t, h, "/api/targets/"+strconv.Itoa(id)+"/pause", `{"resumeAt": "`+resumeAt+`"}`)
+
+	paused := func() bool {
+		t.Helper()
+		code, body := get(t, h.Client, h.Server.URL+"/api/targets/"+strconv.Itoa(id))
+		var target storage.TargetInfo
+		if code != http.StatusOK || json.Unmarshal([]byte(body), &target) != nil {
+			t.Fatalf("GET target: %d %s", code, body)
+		}
+		return target.Paused != nil
+	}
+	h.Tick(30 * time.Minute)
+	if !paused() {
+		t.Fatal("target resumed before its resume time")
+	}
+	h.Tick(time.Hour)
+	if paused() {
+		t.Fatalf("target still paused after %s", resumeAt)
+	}
+}
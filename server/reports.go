@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"uptime/storage"
+)
+
+// Reports cover the last complete period in UTC: the previous day, the
+// previous Monday-to-Monday week or the previous calendar month. The
+// scheduler runs in its own goroutine after each monitor pass and delivers a
+// report once per period, catching up on the first pass after downtime.
+
+var reportPeriods = map[string]bool{"daily": true, "weekly": true, "monthly": true}
+
+var reportFormats = map[string]string{"csv": "text/csv", "html": "text/html"}
+
+// reportWindow returns the last complete period before now.
+func reportWindow(period string, now time.Time) (from, to time.Time) {
+	now = now.UTC()
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "weekly":
+		to = to.AddDate(0, 0, -(int(to.Weekday())+6)%7)
+	case "monthly":
+		to = to.AddDate(0, 0, 1-to.Day())
+	}
+	return shiftPeriod(period, to, -1), to
+}
+
+// shiftPeriod moves t by n periods.
+func shiftPeriod(period string, t time.Time, n int) time.Time {
+	switch period {
+	case "weekly":
+		return t.AddDate(0, 0, 7*n)
+	case "monthly":
+		return t.AddDate(0, n, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// reportRow is one target's line of a report, with the previous period's
+// values for trends.
+type reportRow struct {
+	Current, Previous *Stats
+}
+
+// buildReport computes the report rows for [from, to).
+func buildReport(rep storage.Report, from, to time.Time) ([]reportRow, error) {
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		return nil, err
+	}
+	included := map[int]bool{}
+	for _, id := range rep.TargetIDs {
+		included[id] = true
+	}
+	prevFrom := shiftPeriod(rep.Period, from, -1)
+	var rows []reportRow
+	for _, t := range targets {
+		if len(included) > 0 && !included[t.ID] {
+			continue
+		}
+		cur, err := computeStats(t, from, to)
+		if err != nil {
+			return nil, err
+		}
+		prev, err := computeStats(t, prevFrom, from)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, reportRow{Current: cur, Previous: prev})
+	}
+	return rows, nil
+}
+
+// reportText renders the message body of a report.
+func reportText(rep storage.Report, from, to time.Time, rows []reportRow) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 %s report %q: %s – %s\n", strings.ToUpper(rep.Period[:1])+rep.Period[1:], rep.Name,
+		from.Format("2006-01-02"), to.Add(-time.Second).Format("2006-01-02"))
+	if len(rows) == 0 {
+		b.WriteString("No targets.\n")
+	}
+	for _, row := range rows {
+		cur, prev := row.Current, row.Previous
+		fmt.Fprintf(&b, "%s: ", cur.TargetName)
+		if cur.UptimePercent == nil {
+			b.WriteString("no checks\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%.2f%% uptime", *cur.UptimePercent)
+		if prev.UptimePercent != nil {
+			fmt.Fprintf(&b, " (%+.2f)", *cur.UptimePercent-*prev.UptimePercent)
+		}
+		fmt.Fprintf(&b, ", %d incidents, %.0f min down", cur.Incidents, cur.DowntimeMinutes)
+		if cur.P95Ms != nil {
+			fmt.Fprintf(&b, ", p95 %d ms", *cur.P95Ms)
+			if prev.P95Ms != nil {
+				fmt.Fprintf(&b, " (%+d)", *cur.P95Ms-*prev.P95Ms)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var reportColumns = []string{
+	"target_id", "target", "from", "to", "checks", "failed_checks",
+	"uptime_percent", "previous_uptime_percent", "incidents", "downtime_minutes",
+	"mttr_seconds", "avg_ms", "p95_ms", "previous_p95_ms",
+}
+
+func reportRecord(row reportRow) []string {
+	cur, prev := row.Current, row.Previous
+	return []string{
+		strconv.Itoa(cur.TargetID), cur.TargetName,
+		cur.From.Format(time.RFC3339), cur.To.Format(time.RFC3339),
+		strconv.Itoa(cur.Checks), strconv.Itoa(cur.FailedChecks),
+		formatFloat(cur.UptimePercent), formatFloat(prev.UptimePercent),
+		strconv.Itoa(cur.Incidents), strconv.FormatFloat(cur.DowntimeMinutes, 'f', 1, 64),
+		formatFloat(cur.MTTRSeconds), formatFloat(cur.AvgMs),
+		formatInt(cur.P95Ms), formatInt(prev.P95Ms),
+	}
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', 3, 64)
+}
+
+func formatInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+var reportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<table border="1" cellpadding="4">
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+</body></html>
+`))
+
+// reportAttachment renders the rows as a CSV or HTML document.
+func reportAttachment(rep storage.Report, from time.Time, rows []reportRow) (Attachment, error) {
+	att := Attachment{
+		Name:        fmt.Sprintf("%s-%s.%s", rep.Name, from.Format("2006-01-02"), rep.Format),
+		ContentType: reportFormats[rep.Format],
+	}
+	var buf bytes.Buffer
+	records := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, reportRecord(row))
+	}
+	if rep.Format == "html" {
+		err := reportHTML.Execute(&buf, map[string]any{
+			"Title":   fmt.Sprintf("%s report %s (%s)", rep.Name, from.Format("2006-01-02"), rep.Period),
+			"Columns": reportColumns,
+			"Rows":    records,
+		})
+		if err != nil {
+			return att, err
+		}
+	} else {
+		w := csv.NewWriter(&buf)
+		w.Write(reportColumns)
+		w.WriteAll(records)
+		if err := w.Error(); err != nil {
+			return att, err
+		}
+	}
+	att.Data = buf.Bytes()
+	return att, nil
+}
+
+// deliverReport builds the report for the last complete period before now
+// and sends it.
+func deliverReport(rep storage.Report, now time.Time) error {
+	from, to := reportWindow(rep.Period, now)
+	rows, err := buildReport(rep, from, to)
+	if err != nil {
+		return err
+	}
+	att, err := reportAttachment(rep, from, rows)
+	if err != nil {
+		return err
+	}
+	return sendReport(reportText(rep, from, to, rows), att)
+}
+
+// runReports delivers every report whose current period has not been
+// reported yet.
+func runReports() {
+	reports, err := storage.ListReports()
+	if err != nil {
+		log.Println("report error:", err)
+		return
+	}
+	now := clock.Now()
+	for _, rep := range reports {
+		if _, to := reportWindow(rep.Period, now); rep.LastRunAt != nil && !rep.LastRunAt.Before(to) {
+			continue
+		}
+		if err := deliverReport(rep, now); err != nil {
+			log.Printf("report %q failed: %v", rep.Name, err)
+			continue
+		}
+		if err := storage.SetReportRun(rep.ID, now); err != nil {
+			log.Println("report error:", err)
+		}
+	}
+}
+
+// decodeReport reads and validates a report from a request body.
+func decodeReport(r *http.Request) (storage.Report, error) {
+	var rep storage.Report
+	if err := json.NewDecoder(r.Body).Decode(&rep); err != nil {
+		return rep, err
+	}
+	if rep.Name == "" {
+		return rep, errors.New("name is required")
+	}
+	if !reportPeriods[rep.Period] {
+		return rep, errors.New("period must be daily, weekly or monthly")
+	}
+	if rep.Format == "" {
+		rep.Format = "csv"
+	}
+	if _, ok := reportFormats[rep.Format]; !ok {
+		return rep, errors.New("format must be csv or html")
+	}
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		return rep, err
+	}
+	known := map[int]bool{}
+	for _, t := range targets {
+		known[t.ID] = true
+	}
+	for _, id := range rep.TargetIDs {
+		if !known[id] {
+			return rep, fmt.Errorf("unknown target %d", id)
+		}
+	}
+	return rep, nil
+}
+
+// handleReports serves GET /reports and POST /reports. A new report is first
+// delivered at the end of its current period.
+func handleReports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		reports, err := storage.ListReports()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reports)
+	case http.MethodPost:
+		rep, err := decodeReport(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		now := clock.Now()
+		rep.ID, rep.LastRunAt = 0, &now
+		if rep.ID, err = storage.SaveReport(rep); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeReport(w, rep.ID, http.StatusCreated)
+	}
+}
+
+// handleReport serves GET, PUT and DELETE /reports/{id}.
+func handleReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeReport(w, id, http.StatusOK)
+	case http.MethodPut:
+		rep, err := decodeReport(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rep.ID = id
+		if _, err := storage.SaveReport(rep); errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "report not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeReport(w, id, http.StatusOK)
+	case http.MethodDelete:
+		if err := storage.DeleteReport(id); errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "report not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleSendReport serves POST /reports/{id}/send, delivering the report for
+// the last complete period right away without affecting the schedule.
+func handleSendReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	rep, err := storage.GetReport(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := deliverReport(*rep, clock.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeReport(w http.ResponseWriter, id int64, code int) {
+	rep, err := storage.GetReport(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(rep)
+}
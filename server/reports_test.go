@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"uptime/server/servertest"
+)
+
+// newReportHarness returns a harness with one target whose monitor loop
+// runs hourly, so tests can cover days without ticking through every minute.
+func newReportHarness(t *testing.T) *servertest.Harness {
+	h := servertest.New(t)
+	h.AddTarget("api", "stub://api", false)
+	post(t, h, "/api/settings", `{"frequency": 3600, "timeframeHours": 24}`)
+	h.Tick(0)
+	return h
+}
+
+func post(t *testing.T, h *servertest.Harness, path, body string) {
+	t.Helper()
+	resp, err := h.Client.Post(h.Server.URL+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST %s: %s", path, resp.Status)
+	}
+}
+
+func attachmentNames(h *servertest.Harness) []string {
+	var names []string
+	for _, att := range h.Attachments() {
+		names = append(names, att.Name)
+	}
+	return names
+}
+
+func TestReportsCoverLastCompletePeriod(t *testing.T) {
+	// The harness starts on Monday 2024-01-01 00:00 UTC. Reports created
+	// during a period start with the next one.
+	h := newReportHarness(t)
+	for _, period := range []string{"daily", "weekly", "monthly"} {
+		post(t, h, "/api/reports", `{"name": "`+period+`", "period": "`+period+`", "format": "csv"}`)
+	}
+	h.Tick(23 * time.Hour)
+	if got := attachmentNames(h); len(got) != 0 {
+		t.Fatalf("attachments = %q before the first period ended", got)
+	}
+	h.Tick(time.Hour)
+	msgs := h.Messages()
+	if len(msgs) != 1 || !strings.HasPrefix(msgs[0], `📊 Daily report "daily": 2024-01-01 – 2024-01-01`) {
+		t.Fatalf("messages = %q, want the daily report for 2024-01-01", msgs)
+	}
+
+	h.Tick(30 * 24 * time.Hour) // until 2024-02-01 00:00
+	var daily, other []string
+	for _, name := range attachmentNames(h) {
+		if strings.HasPrefix(name, "daily-") {
+			daily = append(daily, name)
+		} else {
+			other = append(other, name)
+		}
+	}
+	if len(daily) != 31 || daily[30] != "daily-2024-01-31.csv" {
+		t.Fatalf("daily attachments = %q, want one for each day of January", daily)
+	}
+	want := []string{
+		"weekly-2024-01-01.csv", "weekly-2024-01-08.csv", "weekly-2024-01-15.csv",
+		"weekly-2024-01-22.csv", "monthly-2024-01-01.csv",
+	}
+	if !reflect.DeepEqual(other, want) {
+		t.Fatalf("weekly and monthly attachments = %q, want %q", other, want)
+	}
+}
+
+func TestReportsAreSentOncePerPeriod(t *testing.T) {
+	h := newReportHarness(t)
+	post(t, h, "/api/reports", `{"name": "ops", "period": "daily", "format": "html"}`)
+	h.Tick(47 * time.Hour)
+	h.Restart()
+	h.Tick(0)
+
+	want := []string{"ops-2024-01-01.html"}
+	if got := attachmentNames(h); !reflect.DeepEqual(got, want) {
+		t.Fatalf("attachments = %q, want %q", got, want)
+	}
+}
+
+func TestReportsCatchUpAfterDowntime(t *testing.T) {
+	h := newReportHarness(t)
+	post(t, h, "/api/reports", `{"name": "ops", "period": "daily", "format": "csv"}`)
+
+	// Down from Monday until Thursday 11:00: only the last complete day is
+	// reported, once, as soon as the server is back.
+	h.RestartAfter(3*24*time.Hour + 11*time.Hour)
+	h.Tick(0)
+	want := []string{"ops-2024-01-03.csv"}
+	if got := attachmentNames(h); !reflect.DeepEqual(got, want) {
+		t.Fatalf("attachments after downtime = %q, want %q", got, want)
+	}
+
+	h.Tick(14 * time.Hour)
+	want = append(want, "ops-2024-01-04.csv")
+	if got := attachmentNames(h); !reflect.DeepEqual(got, want) {
+		t.Fatalf("attachments = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"net/http"
+
+	"uptime/probes"
+	"uptime/storage"
+)
+
+// apiRoute is one API endpoint. The route table drives both registerAPI and
+// the OpenAPI document, so the two cannot drift apart.
+type apiRoute struct {
+	Method string
+	Path   string // relative to /api
+	// ID is the operationId, which is also the method name in the
+	// generated Go client.
+	ID      string
+	Summary string
+	Handler http.HandlerFunc
+	// Role is the minimum role required; empty means any logged-in user.
+	Role string
+	// Audit, if set, records the state of the changed resource.
+	Audit subjectFunc
+	// Public routes need no login.
+	Public bool
+	Query  []apiParam
+	// Body and Response are values of the JSON request and response types;
+	// nil means no body.
+	Body     any
+	Response any
+	// Status is the success status, 200 by default.
+	Status int
+	// Produces lists the media types of responses that are not JSON.
+	Produces []string
+	// JSONErrors is set when errors are APIError documents rather than
+	// plain text.
+	JSONErrors bool
+}
+
+// apiParam is a query parameter. Type is integer, boolean, string,
+// date-time (RFC 3339) or duration (a Go duration, or days such as "7d").
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+	Enum        []string
+}
+
+var (
+	fromParam  = apiParam{Name: "from", Type: "date-time", Description: "Start of the window."}
+	toParam    = apiParam{Name: "to", Type: "date-time", Description: "End of the window."}
+	limitParam = apiParam{Name: "limit", Type: "integer", Description: "Maximum number of results."}
+)
+
+// apiRoutes lists every route under /api. Routes without a Role are open to
+// every logged-in user (viewers and up). Every mutating request is audited;
+// Audit additionally records the state of what it changes.
+func apiRoutes() []apiRoute {
+	return []apiRoute{
+		{Method: "GET", Path: "/openapi.json", ID: "getOpenAPI", Summary: "This OpenAPI document",
+			Handler: handleOpenAPI, Public: true, Response: map[string]any{}},
+		{Method: "POST", Path: "/auth/login", ID: "login", Summary: "Start a session with a username and password",
+			Handler: handleLogin, Public: true, Body: LoginRequest{}, Response: AuthResponse{}},
+		{Method: "POST", Path: "/auth/logout", ID: "logout", Summary: "End the current session",
+			Handler: handleLogout, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/auth/me", ID: "getMe", Summary: "The logged-in user and the session's CSRF token",
+			Handler: handleMe, Response: AuthResponse{}},
+		{Method: "POST", Path: "/auth/password", ID: "changePassword", Summary: "Change the own password and end all sessions",
+			Handler: handlePassword, Body: PasswordRequest{}, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/auth/providers", ID: "getAuthProviders", Summary: "The login methods offered besides username and password",
+			Handler: handleAuthProviders, Public: true, Response: AuthProviders{}},
+		{Method: "GET", Path: "/auth/oidc/login", ID: "oidcLogin", Summary: "Redirect to the single sign-on provider",
+			Handler: handleOIDCLogin, Public: true, Status: http.StatusFound},
+		{Method: "GET", Path: "/auth/oidc/callback", ID: "oidcCallback", Summary: "Complete a single sign-on login",
+			Handler: handleOIDCCallback, Public: true, Status: http.StatusFound, Query: []apiParam{
+				{Name: "code", Type: "string", Description: "Authorization code."},
+				{Name: "state", Type: "string", Description: "State passed to the provider."},
+			}},
+		{Method: "GET", Path: "/tokens", ID: "listTokens", Summary: "List own API tokens, or every token for admins",
+			Handler: handleTokens, Response: []storage.APIToken{}},
+		{Method: "POST", Path: "/tokens", ID: "createToken", Summary: "Create an API token; the secret is only returned here",
+			Handler: handleTokens, Body: TokenRequest{}, Response: TokenResponse{}, Status: http.StatusCreated},
+		{Method: "DELETE", Path: "/tokens/{id}", ID: "revokeToken", Summary: "Revoke an API token",
+			Handler: handleRevokeToken, Audit: tokenSubject, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/users", ID: "listUsers", Summary: "List users",
+			Handler: handleUsers, Role: storage.RoleAdmin, Response: []storage.User{}},
+		{Method: "POST", Path: "/users", ID: "createUser", Summary: "Create a local user",
+			Handler: handleUsers, Role: storage.RoleAdmin, Audit: userSubject, Body: UserRequest{}, Response: storage.User{}, Status: http.StatusCreated},
+		{Method: "PATCH", Path: "/users/{id}", ID: "updateUser", Summary: "Change a user's role or password",
+			Handler: handleUser, Role: storage.RoleAdmin, Audit: userSubject, Body: UserPatch{}, Response: storage.User{}},
+		{Method: "DELETE", Path: "/users/{id}", ID: "deleteUser", Summary: "Delete a user",
+			Handler: handleUser, Role: storage.RoleAdmin, Audit: userSubject, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/audit", ID: "listAudit", Summary: "Query the audit log, newest first",
+			Handler: handleAudit, Role: storage.RoleAdmin, Response: []storage.AuditEntry{}, Query: []apiParam{
+				{Name: "actor", Type: "string", Description: "Username, or \"config file\"."},
+				{Name: "resource", Type: "string", Description: "Resource such as target:3; a trailing colon matches every resource of the kind."},
+				fromParam, toParam, limitParam,
+				{Name: "before", Type: "integer", Description: "Only entries older than this entry ID."},
+			}},
+		{Method: "GET", Path: "/checks", ID: "listChecks", Summary: "Checks of every target within the configured timeframe",
+			Handler: handleChecks, Response: []CheckResponse{}},
+		{Method: "GET", Path: "/checks/export", ID: "exportChecks", Summary: "Stream raw check rows, oldest first",
+			Handler: handleChecksExport, Produces: []string{"text/csv", "application/x-ndjson"}, Query: []apiParam{
+				{Name: "format", Type: "string", Enum: []string{"csv", "ndjson"}, Description: "Output format, csv by default."},
+				{Name: "target", Type: "integer", Description: "Target ID."},
+				fromParam, toParam,
+			}},
+		{Method: "GET", Path: "/events", ID: "streamEvents", Summary: "Stream new check results (CheckEvent) and status transitions (StateEvent) as Server-Sent Events",
+			Handler: handleEvents, Produces: []string{"text/event-stream"}, Query: []apiParam{
+				{Name: "targets", Type: "string", Description: "Comma-separated target IDs; every target by default."},
+			}},
+		{Method: "GET", Path: "/health", ID: "getHealth", Summary: "Checks that were accepted but could not be stored",
+			Handler: handleHealth, Response: Health{}},
+		{Method: "GET", Path: "/settings", ID: "getSettings", Summary: "Check frequency and chart timeframe",
+			Handler: handleSettings, Response: Settings{}},
+		{Method: "POST", Path: "/settings", ID: "updateSettings", Summary: "Change the settings",
+			Handler: handleSettings, Role: storage.RoleAdmin, Audit: settingsSubject, Body: Settings{}},
+		{Method: "GET", Path: "/targets", ID: "listTargets", Summary: "List targets with their current state",
+			Handler: handleTargets, Response: []storage.TargetInfo{}, JSONErrors: true},
+		{Method: "POST", Path: "/targets", ID: "createTarget", Summary: "Create a target",
+			Handler: handleTargets, Role: storage.RoleAdmin, Audit: targetByBody, Body: TargetRequest{}, Response: storage.TargetInfo{},
+			Status: http.StatusCreated, JSONErrors: true},
+		{Method: "PUT", Path: "/targets", ID: "replaceTarget", Summary: "Replace the target with the body's id",
+			Handler: handleTargets, Role: storage.RoleAdmin, Audit: targetByBody, Body: TargetRequest{}, Response: storage.TargetInfo{},
+			JSONErrors: true},
+		{Method: "DELETE", Path: "/targets", ID: "deleteTargetByQuery", Summary: "Delete the target given by id",
+			Handler: handleTargets, Role: storage.RoleAdmin, Audit: targetByQuery, JSONErrors: true, Query: []apiParam{
+				{Name: "id", Type: "integer", Description: "Target ID."},
+			}},
+		{Method: "GET", Path: "/targets/{id}", ID: "getTarget", Summary: "Get a target",
+			Handler: handleTarget, Response: storage.TargetInfo{}, JSONErrors: true},
+		{Method: "PATCH", Path: "/targets/{id}", ID: "updateTarget", Summary: "Change the given fields of a target",
+			Handler: handleTarget, Role: storage.RoleAdmin, Audit: targetByPath, Body: TargetPatch{}, Response: storage.TargetInfo{},
+			JSONErrors: true},
+		{Method: "DELETE", Path: "/targets/{id}", ID: "deleteTarget", Summary: "Delete a target",
+			Handler: handleTarget, Role: storage.RoleAdmin, Audit: targetByPath, Status: http.StatusNoContent, JSONErrors: true},
+		{Method: "POST", Path: "/targets/{id}/check", ID: "checkTarget", Summary: "Check a target now and return the result; duration is in nanoseconds",
+			Handler: handleCheckTarget, Role: storage.RoleOperator, Response: probes.Result{}, JSONErrors: true},
+		{Method: "POST", Path: "/targets/{id}/pause", ID: "pauseTarget", Summary: "Stop checking a target, optionally until a given time",
+			Handler: handlePauseTarget, Role: storage.RoleOperator, Audit: targetByPath, Body: PauseRequest{}, Response: storage.TargetInfo{},
+			JSONErrors: true},
+		{Method: "POST", Path: "/targets/{id}/resume", ID: "resumeTarget", Summary: "Resume checking a paused target",
+			Handler: handleResumeTarget, Role: storage.RoleOperator, Audit: targetByPath, Response: storage.TargetInfo{}, JSONErrors: true},
+		{Method: "POST", Path: "/targets/pause", ID: "pauseTargets", Summary: "Pause several targets",
+			Handler: handleBulkPause, Role: storage.RoleOperator, Audit: targetsByBody, Body: BulkPauseRequest{}, Response: []storage.TargetInfo{},
+			JSONErrors: true},
+		{Method: "POST", Path: "/targets/resume", ID: "resumeTargets", Summary: "Resume several paused targets",
+			Handler: handleBulkResume, Role: storage.RoleOperator, Audit: targetsByBody, Body: BulkResumeRequest{}, Response: []storage.TargetInfo{},
+			JSONErrors: true},
+		{Method: "GET", Path: "/targets/{id}/checks", ID: "listTargetChecks", Summary: "A target's check history, newest first",
+			Handler: handleTargetChecks, Response: CheckPage{}, JSONErrors: true, Query: []apiParam{
+				fromParam, toParam,
+				{Name: "status", Type: "string", Enum: []string{"up", "down"}, Description: "Only successful or failed checks."},
+				{Name: "limit", Type: "integer", Description: "Page size, 100 by default and at most 1000."},
+				{Name: "cursor", Type: "string", Description: "nextCursor of the previous page."},
+			}},
+		{Method: "GET", Path: "/targets/{id}/series", ID: "getTargetSeries", Summary: "Aggregated chart buckets of a target",
+			Handler: handleTargetSeries, Response: []storage.SeriesBucket{}, JSONErrors: true, Query: []apiParam{
+				fromParam, toParam,
+				{Name: "step", Type: "duration", Description: "Bucket size, a duration or seconds."},
+			}},
+		{Method: "GET", Path: "/targets/{id}/stats", ID: "getTargetStats", Summary: "Availability statistics of a target",
+			Handler: handleTargetStats, Response: Stats{}, Query: statsParams, JSONErrors: true},
+		{Method: "GET", Path: "/stats", ID: "getStats", Summary: "Availability statistics of every target and the fleet",
+			Handler: handleStats, Response: FleetStats{}, Query: statsParams},
+		{Method: "GET", Path: "/slos", ID: "listSLOs", Summary: "List SLOs with their status",
+			Handler: handleSLOs, Response: []SLOStatus{}},
+		{Method: "POST", Path: "/slos", ID: "createSLO", Summary: "Create an SLO",
+			Handler: handleSLOs, Role: storage.RoleAdmin, Audit: sloSubject, Body: storage.SLO{}, Response: SLOStatus{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/slos/{id}", ID: "getSLO", Summary: "Get an SLO with its status",
+			Handler: handleSLO, Response: SLOStatus{}},
+		{Method: "PUT", Path: "/slos/{id}", ID: "updateSLO", Summary: "Replace an SLO",
+			Handler: handleSLO, Role: storage.RoleAdmin, Audit: sloSubject, Body: storage.SLO{}, Response: SLOStatus{}},
+		{Method: "DELETE", Path: "/slos/{id}", ID: "deleteSLO", Summary: "Delete an SLO",
+			Handler: handleSLO, Role: storage.RoleAdmin, Audit: sloSubject, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/reports", ID: "listReports", Summary: "List scheduled reports",
+			Handler: handleReports, Response: []storage.Report{}},
+		{Method: "POST", Path: "/reports", ID: "createReport", Summary: "Create a scheduled report",
+			Handler: handleReports, Role: storage.RoleAdmin, Audit: reportSubject, Body: storage.Report{}, Response: storage.Report{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/reports/{id}", ID: "getReport", Summary: "Get a scheduled report",
+			Handler: handleReport, Response: storage.Report{}},
+		{Method: "PUT", Path: "/reports/{id}", ID: "updateReport", Summary: "Replace a scheduled report",
+			Handler: handleReport, Role: storage.RoleAdmin, Audit: reportSubject, Body: storage.Report{}, Response: storage.Report{}},
+		{Method: "DELETE", Path: "/reports/{id}", ID: "deleteReport", Summary: "Delete a scheduled report",
+			Handler: handleReport, Role: storage.RoleAdmin, Audit: reportSubject, Status: http.StatusNoContent},
+		{Method: "POST", Path: "/reports/{id}/send", ID: "sendReport", Summary: "Send a report for the last complete period now",
+			Handler: handleSendReport, Role: storage.RoleOperator, Status: http.StatusNoContent},
+		{Method: "POST", Path: "/targets/clear", ID: "clearChecks", Summary: "Delete the check history of a target",
+			Handler: handleClear, Role: storage.RoleAdmin, Audit: checksByTarget, JSONErrors: true, Query: []apiParam{
+				{Name: "target", Type: "string", Description: "Target URL."},
+			}},
+		{Method: "POST", Path: "/targets/subscribe", ID: "subscribeTarget", Summary: "Send notifications for a target",
+			Handler: handleSubscribe, Role: storage.RoleOperator, Audit: targetByQuery, JSONErrors: true, Query: []apiParam{
+				{Name: "id", Type: "integer", Description: "Target ID."},
+			}},
+		{Method: "POST", Path: "/targets/unsubscribe", ID: "unsubscribeTarget", Summary: "Stop notifications for a target",
+			Handler: handleUnsubscribe, Role: storage.RoleOperator, Audit: targetByQuery, JSONErrors: true, Query: []apiParam{
+				{Name: "id", Type: "integer", Description: "Target ID."},
+			}},
+		{Method: "POST", Path: "/test-telegram", ID: "testTelegram", Summary: "Send a test notification",
+			Handler: handleTestTelegram, Role: storage.RoleOperator},
+		{Method: "GET", Path: "/incidents", ID: "listIncidents", Summary: "List incidents, newest first",
+			Handler: handleIncidents, Response: []storage.Incident{}, Query: []apiParam{
+				{Name: "target", Type: "integer", Description: "Target ID."},
+				fromParam, toParam,
+				{Name: "open", Type: "boolean", Description: "Only open incidents."},
+				limitParam,
+			}},
+		{Method: "GET", Path: "/incidents/{id}", ID: "getIncident", Summary: "Get an incident",
+			Handler: handleIncident, Response: storage.Incident{}},
+		{Method: "PATCH", Path: "/incidents/{id}", ID: "annotateIncident", Summary: "Set an incident's notes and root cause",
+			Handler: handleIncident, Role: storage.RoleOperator, Audit: incidentSubject, Body: IncidentPatch{}, Response: storage.Incident{}},
+		{Method: "GET", Path: "/backups", ID: "listBackups", Summary: "List database backups",
+			Handler: handleBackups, Role: storage.RoleAdmin, Response: []storage.BackupInfo{}},
+		{Method: "POST", Path: "/backups", ID: "createBackup", Summary: "Back up the database now",
+			Handler: handleBackups, Role: storage.RoleAdmin, Response: storage.BackupInfo{}, Status: http.StatusCreated},
+		{Method: "GET", Path: "/export", ID: "export", Summary: "Export settings, targets and optionally history",
+			Handler: handleExport, Role: storage.RoleAdmin, Response: ExportDocument{}, Query: []apiParam{
+				{Name: "credentials", Type: "string", Enum: []string{"include"}, Description: "Include passwords."},
+				{Name: "history", Type: "boolean", Description: "Include check history."},
+				fromParam, toParam,
+			}},
+		{Method: "POST", Path: "/import", ID: "import", Summary: "Apply an export document",
+			Handler: handleImport, Role: storage.RoleAdmin, Audit: configSubject, Body: ExportDocument{}, Response: ImportResult{}, JSONErrors: true, Query: []apiParam{
+				{Name: "mode", Type: "string", Enum: []string{"merge", "replace"}, Description: "merge by default."},
+				{Name: "dryRun", Type: "boolean", Description: "Only report the changes."},
+			}},
+	}
+}
+
+var statsParams = []apiParam{
+	{Name: "window", Type: "duration", Description: "Window ending now, such as 24h or 30d; 24h by default."},
+	fromParam, toParam,
+}
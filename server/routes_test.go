@@ -0,0 +1,187 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"uptime/server"
+	"uptime/server/servertest"
+)
+
+// TestEveryRoute calls each operation of the OpenAPI document once through
+// harness clients, so every route's success response is checked against the
+// document. A route added without a case here fails the test.
+func TestEveryRoute(t *testing.T) {
+	t.Setenv("BACKUP_DIR", t.TempDir())
+	h := servertest.New(t)
+	h.StartOIDC(nil, "viewer")
+	api := h.AddTarget("api", "stub://api", true)
+	h.SetResult("stub://api", false, "connection refused")
+	h.Tick(time.Minute)
+	h.SetResult("stub://api", true, "")
+	h.Tick(time.Minute)
+
+	// Values captured from responses, substituted for {name} in paths and
+	// bodies.
+	vars := map[string]string{"api": strconv.Itoa(api)}
+	other := h.Login(servertest.AdminUser, servertest.AdminPassword)
+	sso := h.Login(servertest.AdminUser, servertest.AdminPassword)
+	sso.CheckRedirect = func(req *http.Request, _ []*http.Request) error {
+		if req.URL.Path == "/" {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	login := func(user, password string) func() *http.Client {
+		return func() *http.Client { return h.Login(user, password) }
+	}
+
+	type routeCase struct {
+		op      string // operation as "METHOD /path" in the document
+		url     string // defaults to the operation's path
+		body    string
+		client  func() *http.Client
+		capture string // saves the response's id under this name
+	}
+	cases := []routeCase{
+		{op: "GET /openapi.json"},
+		{op: "POST /auth/login", body: `{"username": "` + servertest.AdminUser + `", "password": "` + servertest.AdminPassword + `"}`,
+			client: func() *http.Client { return other }},
+		{op: "GET /auth/me"},
+		{op: "GET /auth/providers"},
+		{op: "GET /auth/oidc/login", client: func() *http.Client { return sso }},
+		{op: "GET /tokens"},
+		{op: "POST /tokens", body: `{"name": "ci"}`, capture: "token"},
+		{op: "DELETE /tokens/{id}", url: "/tokens/{token}"},
+		{op: "GET /users"},
+		{op: "POST /users", body: `{"username": "bob", "password": "bob-password", "role": "viewer"}`, capture: "bob"},
+		{op: "POST /auth/password", body: `{"currentPassword": "bob-password", "newPassword": "bob-password-2"}`,
+			client: login("bob", "bob-password")},
+		{op: "PATCH /users/{id}", url: "/users/{bob}", body: `{"role": "operator"}`},
+		{op: "DELETE /users/{id}", url: "/users/{bob}"},
+		{op: "GET /checks"},
+		{op: "GET /checks/export"},
+		{op: "GET /events"},
+		{op: "GET /health"},
+		{op: "GET /settings"},
+		{op: "POST /settings", body: `{"frequency": 60, "timeframeHours": 24}`},
+		{op: "GET /targets"},
+		{op: "POST /targets", body: `{"name": "web", "url": "stub://web", "type": "stub"}`, capture: "web"},
+		{op: "POST /targets", body: `{"name": "db", "url": "stub://db", "type": "stub"}`, capture: "db"},
+		{op: "PUT /targets", body: `{"id": {web}, "name": "web", "url": "stub://www", "type": "stub"}`},
+		{op: "GET /targets/{id}", url: "/targets/{web}"},
+		{op: "PATCH /targets/{id}", url: "/targets/{web}", body: `{"name": "www"}`},
+		{op: "POST /targets/{id}/check", url: "/targets/{web}/check"},
+		{op: "POST /targets/{id}/pause", url: "/targets/{web}/pause", body: `{"resumeIn": "1h"}`},
+		{op: "POST /targets/{id}/resume", url: "/targets/{web}/resume"},
+		{op: "POST /targets/pause", body: `{"ids": [{web}, {db}]}`},
+		{op: "POST /targets/resume", body: `{"ids": [{web}, {db}]}`},
+		{op: "POST /targets/subscribe", url: "/targets/subscribe?id={web}"},
+		{op: "POST /targets/unsubscribe", url: "/targets/unsubscribe?id={web}"},
+		{op: "GET /targets/{id}/checks", url: "/targets/{api}/checks?limit=1"},
+		{op: "GET /targets/{id}/series", url: "/targets/{api}/series"},
+		{op: "GET /targets/{id}/stats", url: "/targets/{api}/stats"},
+		{op: "GET /stats"},
+		{op: "POST /targets/clear", url: "/targets/clear?target=stub://www"},
+		{op: "DELETE /targets", url: "/targets?id={web}"},
+		{op: "DELETE /targets/{id}", url: "/targets/{db}"},
+		{op: "GET /slos"},
+		{op: "POST /slos", body: `{"name": "api", "targetIds": [{api}], "objective": 99.9, "windowDays": 30}`, capture: "slo"},
+		{op: "GET /slos/{id}", url: "/slos/{slo}"},
+		{op: "PUT /slos/{id}", url: "/slos/{slo}", body: `{"name": "api", "objective": 99.5, "latencyThresholdMs": 500, "windowDays": 7}`},
+		{op: "DELETE /slos/{id}", url: "/slos/{slo}"},
+		{op: "GET /reports"},
+		{op: "POST /reports", body: `{"name": "ops", "period": "daily", "format": "csv"}`, capture: "report"},
+		{op: "GET /reports/{id}", url: "/reports/{report}"},
+		{op: "PUT /reports/{id}", url: "/reports/{report}", body: `{"name": "ops", "period": "weekly", "format": "html"}`},
+		{op: "POST /reports/{id}/send", url: "/reports/{report}/send"},
+		{op: "DELETE /reports/{id}", url: "/reports/{report}"},
+		{op: "POST /test-telegram"},
+		{op: "GET /incidents", url: "/incidents?target={api}"},
+		{op: "GET /incidents/{id}", url: "/incidents/{incident}"},
+		{op: "PATCH /incidents/{id}", url: "/incidents/{incident}", body: `{"notes": "deploy", "rootCause": "bad config"}`},
+		{op: "GET /backups"},
+		{op: "POST /backups"},
+		{op: "GET /export", url: "/export?history=true"},
+		{op: "POST /import", url: "/import?dryRun=true", body: `{"version": 1, "targets": [{"name": "api", "url": "stub://api", "type": "stub"}]}`},
+		{op: "GET /audit", url: "/audit?resource=target:"},
+		{op: "POST /auth/logout", client: login(servertest.AdminUser, servertest.AdminPassword)},
+	}
+
+	covered := map[string]bool{}
+	for _, tc := range cases {
+		method, path, _ := strings.Cut(tc.op, " ")
+		covered[tc.op] = true
+		if tc.op == "GET /auth/oidc/login" {
+			// Its redirects end in the callback.
+			covered["GET /auth/oidc/callback"] = true
+		}
+		if tc.op == "GET /incidents" {
+			// The stub target's outage above opened the first incident.
+			vars["incident"] = "1"
+		}
+		subst := func(s string) string {
+			for k, v := range vars {
+				s = strings.ReplaceAll(s, "{"+k+"}", v)
+			}
+			return s
+		}
+		url := path
+		if tc.url != "" {
+			url = subst(tc.url)
+		}
+		var body io.Reader
+		if tc.body != "" {
+			body = strings.NewReader(subst(tc.body))
+		}
+		req, err := http.NewRequest(method, h.Server.URL+"/api"+url, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		c := h.Client
+		if tc.client != nil {
+			c = tc.client()
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+		var data []byte
+		if resp.Header.Get("Content-Type") != "text/event-stream" {
+			data, _ = io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			t.Fatalf("%s %s: %s: %s", method, url, resp.Status, data)
+		}
+		if tc.capture != "" {
+			var v struct{ ID int64 }
+			if err := json.Unmarshal(data, &v); err != nil || v.ID == 0 {
+				t.Fatalf("%s %s: no id in %s", method, url, data)
+			}
+			vars[tc.capture] = strconv.FormatInt(v.ID, 10)
+		}
+	}
+
+	var missing []string
+	for path, ops := range server.OpenAPI()["paths"].(map[string]map[string]any) {
+		for method := range ops {
+			if op := strings.ToUpper(method) + " " + path; !covered[op] {
+				missing = append(missing, op)
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Fatalf("no case for %q", missing)
+	}
+}
@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"uptime"
 	"uptime/storage"
 
@@ -12,17 +18,77 @@ import (
 	mw "github.com/g-h-miles/std-middleware"
 )
 
-func Run() error {
+// Options configures Run.
+type Options struct {
+	// ConfigFile is an optional declarative config file (see Config).
+	ConfigFile string
+	// SeedExamples adds example targets when a new database is
+	// initialized.
+	SeedExamples bool
+}
+
+// shutdownTimeout bounds how long Run waits for requests in flight when it
+// is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// Run opens the database, starts the monitor and serves the API and the
+// frontend on :8080 until it receives SIGINT or SIGTERM. It then finishes
+// requests in flight, stops the monitor and closes the database, which
+// commits the queued check results.
+func Run(opts Options) error {
 	if err := storage.Init(); err != nil {
 		return err
 	}
-	if s, err := storage.GetSettings(); err == nil {
-		mu.Lock()
-		settings = &Settings{Frequency: s.Frequency, TimeframeHours: s.TimeframeHours}
-		mu.Unlock()
+	if err := storage.Bootstrap(opts.SeedExamples); err != nil {
+		return err
+	}
+	if err := ensureAdmin(); err != nil {
+		return err
+	}
+	if err := configureOIDC(); err != nil {
+		return err
+	}
+	loadSettings()
+	if opts.ConfigFile != "" {
+		if err := startConfig(opts.ConfigFile); err != nil {
+			return err
+		}
+	} else if names, err := storage.ReleaseManagedTargets(); err != nil {
+		return err
+	} else if len(names) > 0 {
+		log.Printf("config: no config file, targets %v are editable again", names)
 	}
 	StartMonitoring()
+	startBackupSchedule()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{Addr: ":8080", Handler: Handler()}
+	// Event streams never go idle, so end them or Shutdown waits for them.
+	srv.RegisterOnShutdown(events.reset)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	log.Println("listening on :8080")
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		log.Println("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = srv.Shutdown(shutdownCtx)
+	}
+	StopMonitoring()
+	if cerr := storage.Close(); cerr != nil {
+		err = errors.Join(err, fmt.Errorf("closing database: %w", cerr))
+	}
+	return err
+}
 
+// Handler builds the HTTP handler serving the API under /api, which requires
+// a login, and the frontend for every other path.
+func Handler() http.Handler {
 	spaHandler := mw.SPA(mw.SPAConfig{
 		DistFS:    uptime.FrontEndDist,
 		DistPath:  uptime.FrontEndDistPath,
@@ -39,7 +105,5 @@ func Run() error {
 	frontend.GET("/{everything...}", spaHandler(nil))
 
 	multi.Default(frontend)
-
-	log.Println("listening on :8080")
-	return http.ListenAndServe(":8080", multi)
+	return requireAuth(auditRequests(multi))
 }
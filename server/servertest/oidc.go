@@ -0,0 +1,198 @@
+package servertest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"uptime/server"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+)
+
+// OIDCUser is the identity the mock provider logs in.
+type OIDCUser struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+// OIDCProvider is a mock OpenID Connect identity provider. Its authorization
+// endpoint logs in the user passed to Login without showing a page, and its
+// token endpoint checks the client secret and the PKCE verifier.
+type OIDCProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	// EditClaims, if set, may change the claims of each ID token before it
+	// is signed, e.g. to send a wrong nonce.
+	EditClaims func(claims map[string]any)
+
+	h         *Harness
+	key       *rsa.PrivateKey
+	discovery *oidctest.Server
+
+	mu    sync.Mutex
+	user  OIDCUser
+	codes map[string]oidcGrant
+}
+
+type oidcGrant struct {
+	user      OIDCUser
+	nonce     string
+	challenge string
+}
+
+// StartOIDC starts a mock identity provider and enables single sign-on
+// against it with the given group to role mapping and default role. It is
+// stopped and single sign-on disabled again via t.Cleanup.
+func (h *Harness) StartOIDC(groupRoles map[string]string, defaultRole string) *OIDCProvider {
+	h.T.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		h.T.Fatalf("generate key: %v", err)
+	}
+	p := &OIDCProvider{
+		ClientID:     "uptime",
+		ClientSecret: "servertest-secret",
+		h:            h,
+		key:          key,
+		discovery: &oidctest.Server{PublicKeys: []oidctest.PublicKey{
+			{PublicKey: key.Public(), KeyID: "test", Algorithm: oidc.RS256},
+		}},
+		codes: make(map[string]oidcGrant),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", p.discovery)
+	mux.HandleFunc("/auth", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	p.discovery.SetIssuer(p.Server.URL)
+
+	err = server.SetOIDC(&server.OIDCConfig{
+		Issuer:       p.Server.URL,
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		GroupRoles:   groupRoles,
+		DefaultRole:  defaultRole,
+	})
+	if err != nil {
+		h.T.Fatalf("enable oidc: %v", err)
+	}
+	h.T.Cleanup(func() {
+		server.SetOIDC(nil)
+		p.Server.Close()
+	})
+	return p
+}
+
+// Login runs the browser login flow for user and returns a client holding
+// the resulting session together with the response that ended the flow:
+// the redirect to the frontend on success, or the callback's error.
+func (p *OIDCProvider) Login(user OIDCUser) (*http.Client, *http.Response) {
+	p.h.T.Helper()
+	p.mu.Lock()
+	p.user = user
+	p.mu.Unlock()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, _ []*http.Request) error {
+		if req.URL.Path == "/" {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}}
+	resp, err := client.Get(p.h.Server.URL + "/api/auth/oidc/login")
+	if err != nil {
+		p.h.T.Fatalf("oidc login: %v", err)
+	}
+	resp.Body.Close()
+
+	u, _ := url.Parse(p.h.Server.URL)
+	for _, c := range jar.Cookies(u) {
+		if c.Name == "uptime_csrf" {
+			client.Transport = csrfTransport{h: p.h, token: c.Value}
+		}
+	}
+	return client, resp
+}
+
+func (p *OIDCProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != p.ClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := rand.Text()
+	p.mu.Lock()
+	p.codes[code] = oidcGrant{user: p.user, nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *OIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id != p.ClientID || secret != p.ClientSecret {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	p.mu.Lock()
+	grant, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	// ID tokens are checked against the real time, not the harness clock.
+	now := time.Now()
+	claims := map[string]any{
+		"iss":                p.Server.URL,
+		"aud":                p.ClientID,
+		"sub":                grant.user.Subject,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              grant.nonce,
+		"preferred_username": grant.user.Username,
+		"groups":             grant.user.Groups,
+	}
+	if p.EditClaims != nil {
+		p.EditClaims(claims)
+	}
+	payload, _ := json.Marshal(claims)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     oidctest.SignIDToken(p.key, "test", oidc.RS256, string(payload)),
+	})
+}
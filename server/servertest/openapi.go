@@ -0,0 +1,257 @@
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"uptime/server"
+)
+
+// The harness' clients check every API response against the OpenAPI
+// document: the route and status must be documented, the content type must
+// be one the document lists and JSON bodies must match their schema. A
+// mismatch fails the test, so the document cannot silently drift from the
+// handlers.
+
+type openAPISpec struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type specSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Format               string                 `json:"format"`
+	Nullable             bool                   `json:"nullable"`
+	Enum                 []any                  `json:"enum"`
+	Items                *specSchema            `json:"items"`
+	Properties           map[string]*specSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	AllOf                []*specSchema          `json:"allOf"`
+}
+
+func loadOpenAPI() (*openAPISpec, error) {
+	b, err := json.Marshal(server.OpenAPI())
+	if err != nil {
+		return nil, err
+	}
+	var spec openAPISpec
+	return &spec, json.Unmarshal(b, &spec)
+}
+
+// checkOpenAPI validates a response to a request to the harness' API. The
+// response body is read and replaced so the caller can still consume it.
+func (h *Harness) checkOpenAPI(req *http.Request, resp *http.Response) {
+	path, ok := strings.CutPrefix(req.URL.Path, "/api")
+	if !ok || h.Server.URL != "http://"+req.URL.Host {
+		return
+	}
+	var body []byte
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		// Event streams do not end; only their status and type are checked.
+		var err error
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+	}
+	if err := h.spec.check(req.Method, path, resp, body); err != nil {
+		h.T.Errorf("openapi: %s %s: %v", req.Method, req.URL.Path, err)
+	}
+}
+
+func (s *openAPISpec) check(method, path string, resp *http.Response, body []byte) error {
+	op, ok := s.operation(strings.ToLower(method), path)
+	if !ok {
+		return fmt.Errorf("route is not documented")
+	}
+	res, ok := op.Responses[strconv.Itoa(resp.StatusCode)]
+	if !ok {
+		if resp.StatusCode < 400 {
+			return fmt.Errorf("status %d is not documented", resp.StatusCode)
+		}
+		res = op.Responses["default"]
+	}
+	if len(body) == 0 && method == http.MethodHead {
+		return nil
+	}
+	if len(res.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return fmt.Errorf("status %d has an undocumented body: %.200s", resp.StatusCode, body)
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	content, ok := res.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("status %d: content type %q is not documented", resp.StatusCode, mediaType)
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("status %d: invalid JSON: %v", resp.StatusCode, err)
+	}
+	return s.validate(v, content.Schema, "body")
+}
+
+// operation finds the operation serving path, preferring static segments
+// over parameters as the router does.
+func (s *openAPISpec) operation(method, path string) (specOperation, bool) {
+	var templates []string
+	for t := range s.Paths {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+	segs := strings.Split(path, "/")
+	for _, t := range templates {
+		op, ok := s.Paths[t][method]
+		if !ok {
+			continue
+		}
+		tsegs := strings.Split(t, "/")
+		if len(tsegs) != len(segs) {
+			continue
+		}
+		match := true
+		for i := range tsegs {
+			if tsegs[i] != segs[i] && !strings.HasPrefix(tsegs[i], "{") {
+				match = false
+				break
+			}
+		}
+		if match {
+			return op, true
+		}
+	}
+	return specOperation{}, false
+}
+
+func (s *openAPISpec) validate(v any, sch *specSchema, at string) error {
+	if sch == nil {
+		return nil
+	}
+	if sch.Ref != "" {
+		return s.validate(v, s.Components.Schemas[strings.TrimPrefix(sch.Ref, "#/components/schemas/")], at)
+	}
+	if v == nil {
+		if sch.Nullable {
+			return nil
+		}
+		if len(sch.AllOf) == 0 && sch.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+	for _, sub := range sch.AllOf {
+		if err := s.validate(v, sub, at); err != nil {
+			return err
+		}
+	}
+	if len(sch.Enum) > 0 {
+		found := false
+		for _, e := range sch.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", at, v, sch.Enum)
+		}
+	}
+
+	switch sch.Type {
+	case "":
+		return nil
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %T", at, v)
+		}
+		if sch.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected an integer, got %T", at, v)
+		}
+		if _, err := n.Int64(); err != nil {
+			return fmt.Errorf("%s: %s is not an integer", at, n)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s: expected a number, got %T", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", at, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", at, v)
+		}
+		for i, item := range items {
+			if err := s.validate(item, sch.Items, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", at, v)
+		}
+		for _, name := range sch.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		var additional *specSchema
+		closed := string(sch.AdditionalProperties) == "false"
+		if len(sch.AdditionalProperties) > 0 && !closed {
+			json.Unmarshal(sch.AdditionalProperties, &additional)
+		}
+		for name, val := range obj {
+			prop, ok := sch.Properties[name]
+			if !ok {
+				if closed {
+					return fmt.Errorf("%s: undocumented property %q", at, name)
+				}
+				prop = additional
+			}
+			if err := s.validate(val, prop, at+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
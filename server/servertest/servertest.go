@@ -0,0 +1,270 @@
+// Package servertest runs the monitor and its HTTP API against an in-memory
+// database, a fake clock and stub probes so they can be exercised end-to-end
+// in unit tests.
+//
+//	h := servertest.New(t)
+//	h.AddTarget("api", "stub://api", true)
+//	h.SetResult("stub://api", false, "connection refused")
+//	h.Tick(time.Minute) // the monitor loop's next pass is due
+//	// h.Messages() now holds the down notification
+//
+// The monitor loop runs as in production, but only moves when the fake clock
+// does: Tick advances the clock and waits for the passes that fall due.
+//
+// API requests should go through h.Client, which is logged in as an admin.
+// Responses to the clients the harness hands out are checked against the
+// OpenAPI document, and mismatches fail the test.
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"uptime/client"
+	"uptime/probes"
+	"uptime/server"
+	"uptime/storage"
+)
+
+// StubType is the target type served by the harness' stub probes.
+const StubType = "stub"
+
+// Credentials of the user New creates and logs Client in as.
+const (
+	AdminUser     = "admin"
+	AdminPassword = "servertest-admin"
+)
+
+// Harness wires the server to test doubles. All fields are ready to use after
+// New returns.
+type Harness struct {
+	T      testing.TB
+	Clock  *FakeClock
+	Server *httptest.Server
+	// Client is logged in to Server and sends the session's CSRF token
+	// with every request.
+	Client *http.Client
+
+	mu          sync.Mutex
+	results     map[string]probes.Result
+	messages    []string
+	attachments []server.Attachment
+	spec        *openAPISpec
+}
+
+// New opens a fresh in-memory database, installs the fake clock, stub probes
+// and a capturing notifier, and starts an httptest server for the API.
+// Everything is torn down via t.Cleanup.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	if err := storage.OpenMemory(); err != nil {
+		t.Fatalf("open memory storage: %v", err)
+	}
+	h := &Harness{
+		T:       t,
+		Clock:   NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		results: make(map[string]probes.Result),
+	}
+	spec, err := loadOpenAPI()
+	if err != nil {
+		t.Fatalf("load openapi document: %v", err)
+	}
+	h.spec = spec
+	storage.RegisterProbe(StubType, func(url, _, _ string) probes.Target {
+		return stubProbe{h: h, url: url}
+	})
+	server.SetClock(h.Clock)
+	server.SetNotifier(h.notify)
+	server.SetReportNotifier(h.notifyReport)
+	server.ResetState()
+	server.StartMonitoring()
+	h.Server = httptest.NewServer(server.Handler())
+	if _, err := storage.CreateUser(AdminUser, AdminPassword, storage.RoleAdmin, h.Clock.Now()); err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	h.Client = h.Login(AdminUser, AdminPassword)
+
+	t.Cleanup(func() {
+		// Ends event streams, which would otherwise keep Close waiting.
+		h.Server.CloseClientConnections()
+		h.Server.Close()
+		server.StopMonitoring()
+		server.SetClock(nil)
+		server.SetNotifier(nil)
+		server.SetReportNotifier(nil)
+		server.ResetState()
+		storage.Close()
+	})
+	return h
+}
+
+// Login returns a client with a session for the given user.
+func (h *Harness) Login(username, password string) *http.Client {
+	h.T.Helper()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	resp, err := client.Post(h.Server.URL+"/api/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		h.T.Fatalf("login: %v", err)
+	}
+	defer resp.Body.Close()
+	var auth server.AuthResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&auth) != nil {
+		h.T.Fatalf("login as %s: %s", username, resp.Status)
+	}
+	client.Transport = csrfTransport{h: h, token: auth.CSRFToken}
+	return client
+}
+
+type csrfTransport struct {
+	h     *Harness
+	token string
+}
+
+func (t csrfTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-CSRF-Token", t.token)
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err == nil {
+		t.h.checkOpenAPI(r, resp)
+	}
+	return resp, err
+}
+
+// AddTarget stores a stub target and returns its ID. Its probe reports
+// whatever SetResult last recorded for url, and succeeds by default.
+func (h *Harness) AddTarget(name, url string, subscribed bool) int {
+	h.T.Helper()
+	id, err := storage.AddTarget(name, url, StubType, "", "")
+	if err != nil {
+		h.T.Fatalf("add target: %v", err)
+	}
+	if subscribed {
+		if err := storage.SubscribeTarget(id); err != nil {
+			h.T.Fatalf("subscribe target: %v", err)
+		}
+	}
+	return id
+}
+
+// SetResult sets the outcome of the next checks of url.
+func (h *Harness) SetResult(url string, ok bool, message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results[url] = probes.Result{Target: url, Type: StubType, Status: ok, Message: message}
+}
+
+// Tick advances the fake clock by d and returns once the monitor loop has
+// run every pass that fell due on the way, as well as any pass requested
+// since the last Tick, for example by an API call changing the settings.
+// Tick(0) only does the latter. The loop checks all targets when New starts
+// it and then every settings frequency (60s by default).
+func (h *Harness) Tick(d time.Duration) {
+	h.T.Helper()
+	end := h.Clock.Now().Add(d)
+	for {
+		next := server.MonitorIdle()
+		if next.IsZero() {
+			h.T.Fatalf("monitor loop is not running")
+		}
+		if next.After(end) {
+			break
+		}
+		h.Clock.Advance(next.Sub(h.Clock.Now()))
+	}
+	h.Clock.Advance(end.Sub(h.Clock.Now()))
+	if err := storage.Flush(); err != nil {
+		h.T.Fatalf("flush checks: %v", err)
+	}
+}
+
+// Restart simulates a restart of the server: it stops the monitor loop,
+// drops all in-memory state and starts the loop again, which reloads the
+// state from storage and runs a pass right away.
+func (h *Harness) Restart() {
+	h.T.Helper()
+	h.RestartAfter(0)
+}
+
+// RestartAfter is like Restart, but the server stays down while the clock
+// advances by d.
+func (h *Harness) RestartAfter(d time.Duration) {
+	h.T.Helper()
+	server.StopMonitoring()
+	if err := storage.Flush(); err != nil {
+		h.T.Fatalf("flush checks: %v", err)
+	}
+	h.Clock.Advance(d)
+	server.ResetState()
+	server.StartMonitoring()
+}
+
+// Events opens the live event stream as h.Client with the given query, such
+// as "targets=1", and returns once it is connected. Events of the next Tick
+// are then ready to read with Next. The stream is closed when the test ends.
+func (h *Harness) Events(query string) *client.EventStream {
+	h.T.Helper()
+	resp, err := h.Client.Get(h.Server.URL + "/api/events?" + query)
+	if err != nil {
+		h.T.Fatalf("events: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		h.T.Fatalf("events: %s", resp.Status)
+	}
+	h.T.Cleanup(func() { resp.Body.Close() })
+	return client.NewEventStream(resp.Body)
+}
+
+// Messages returns the notifications sent so far.
+func (h *Harness) Messages() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.messages...)
+}
+
+// Attachments returns the documents sent with reports so far. The report
+// messages themselves are included in Messages.
+func (h *Harness) Attachments() []server.Attachment {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]server.Attachment(nil), h.attachments...)
+}
+
+func (h *Harness) notifyReport(msg string, att server.Attachment) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, msg)
+	h.attachments = append(h.attachments, att)
+	return nil
+}
+
+func (h *Harness) notify(msg string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, msg)
+	return nil
+}
+
+type stubProbe struct {
+	h   *Harness
+	url string
+}
+
+func (p stubProbe) Check() probes.Result {
+	p.h.mu.Lock()
+	res, ok := p.h.results[p.url]
+	p.h.mu.Unlock()
+	if !ok {
+		res = probes.Result{Target: p.url, Type: StubType, Status: true}
+	}
+	res.CheckedAt = p.h.Clock.Now()
+	return res
+}
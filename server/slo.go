@@ -0,0 +1,295 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"uptime/storage"
+)
+
+// burnRule is one multi-window burn-rate alert: it fires when the error
+// budget burns faster than Rate over both the long and the short window. The
+// short window makes the alert reset quickly once the problem is fixed.
+type burnRule struct {
+	Long, Short time.Duration
+	Rate        float64
+}
+
+// burnRules are the usual page-level rules for a 30 day budget: 2% of the
+// budget spent in an hour, or 5% in six hours.
+var burnRules = []burnRule{
+	{Long: time.Hour, Short: 5 * time.Minute, Rate: 14.4},
+	{Long: 6 * time.Hour, Short: 30 * time.Minute, Rate: 6},
+}
+
+// sloEvalInterval limits how often burn rates are evaluated.
+const sloEvalInterval = time.Minute
+
+var (
+	sloMutex    sync.Mutex
+	lastSLOEval time.Time
+)
+
+// SLOStatus is an SLO with its current compliance and error budget over its
+// window, which covers the checks after From up to and including To. Compliance and ErrorBudgetRemaining are nil when the window has no
+// checks. ErrorBudgetRemaining is a fraction of the budget and goes negative
+// once the SLO is breached.
+type SLOStatus struct {
+	storage.SLO
+	From                 time.Time          `json:"from"`
+	To                   time.Time          `json:"to"`
+	Checks               int                `json:"checks"`
+	GoodChecks           int                `json:"goodChecks"`
+	Compliance           *float64           `json:"compliance"`
+	ErrorBudgetRemaining *float64           `json:"errorBudgetRemaining"`
+	BurnRates            map[string]float64 `json:"burnRates"`
+}
+
+func computeSLOStatus(s storage.SLO, now time.Time) (SLOStatus, error) {
+	st := SLOStatus{
+		SLO:       s,
+		From:      now.AddDate(0, 0, -s.WindowDays),
+		To:        now,
+		BurnRates: map[string]float64{},
+	}
+	total, good, err := storage.CountGoodChecks(s.TargetIDs, s.LatencyThresholdMs, st.From, st.To)
+	if err != nil {
+		return st, err
+	}
+	st.Checks, st.GoodChecks = total, good
+	if total > 0 {
+		compliance := float64(good) / float64(total) * 100
+		allowed := (100 - s.Objective) / 100 * float64(total)
+		remaining := 1 - float64(total-good)/allowed
+		st.Compliance, st.ErrorBudgetRemaining = &compliance, &remaining
+	}
+	for _, rule := range burnRules {
+		for _, d := range []time.Duration{rule.Long, rule.Short} {
+			key := formatWindow(d)
+			if _, ok := st.BurnRates[key]; ok {
+				continue
+			}
+			rate, err := burnRate(s, now, d)
+			if err != nil {
+				return st, err
+			}
+			st.BurnRates[key] = rate
+		}
+	}
+	return st, nil
+}
+
+// burnRate is the ratio of bad checks over the last d to the ratio the
+// objective allows; 1 spends the budget exactly over the SLO window.
+func burnRate(s storage.SLO, now time.Time, d time.Duration) (float64, error) {
+	total, good, err := storage.CountGoodChecks(s.TargetIDs, s.LatencyThresholdMs, now.Add(-d), now)
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	return float64(total-good) / float64(total) / ((100 - s.Objective) / 100), nil
+}
+
+func formatWindow(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+// evaluateSLOs checks every SLO against burnRules and notifies when an alert
+// starts or stops firing. It runs at most once per sloEvalInterval.
+func evaluateSLOs() {
+	now := clock.Now()
+	sloMutex.Lock()
+	defer sloMutex.Unlock()
+	if !lastSLOEval.IsZero() && now.Sub(lastSLOEval) < sloEvalInterval {
+		return
+	}
+	lastSLOEval = now
+
+	if err := storage.Flush(); err != nil {
+		log.Println("slo flush error:", err)
+	}
+	slos, err := storage.ListSLOs()
+	if err != nil {
+		log.Println("slo error:", err)
+		return
+	}
+	for _, s := range slos {
+		st, err := computeSLOStatus(s, now)
+		if err != nil {
+			log.Println("slo error:", err)
+			continue
+		}
+		var fired *burnRule
+		for i, rule := range burnRules {
+			if st.BurnRates[formatWindow(rule.Long)] > rule.Rate && st.BurnRates[formatWindow(rule.Short)] > rule.Rate {
+				fired = &burnRules[i]
+				break
+			}
+		}
+		if (fired != nil) == s.Alerting {
+			continue
+		}
+		var msg string
+		if fired != nil {
+			msg = fmt.Sprintf("🔥 SLO %s is burning its error budget %.1fx too fast over the last %s",
+				s.Name, st.BurnRates[formatWindow(fired.Long)], formatWindow(fired.Long))
+			if st.ErrorBudgetRemaining != nil {
+				msg += fmt.Sprintf(" (%.0f%% of the budget left)", *st.ErrorBudgetRemaining*100)
+			}
+		} else {
+			msg = "✅ SLO " + s.Name + " burn rate is back to normal"
+		}
+		if err := sendMessage(msg); err != nil {
+			log.Println("slo notify error:", err)
+			continue
+		}
+		if err := storage.SetSLOAlerting(s.ID, fired != nil); err != nil {
+			log.Println("slo error:", err)
+		}
+	}
+}
+
+func resetSLOEval() {
+	sloMutex.Lock()
+	lastSLOEval = time.Time{}
+	sloMutex.Unlock()
+}
+
+// decodeSLO reads and validates an SLO from a request body.
+func decodeSLO(r *http.Request) (storage.SLO, error) {
+	var s storage.SLO
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		return s, err
+	}
+	if s.Name == "" {
+		return s, errors.New("name is required")
+	}
+	if s.Objective <= 0 || s.Objective >= 100 {
+		return s, errors.New("objective must be a percentage between 0 and 100")
+	}
+	if s.LatencyThresholdMs < 0 {
+		return s, errors.New("latencyThresholdMs must not be negative")
+	}
+	if s.WindowDays == 0 {
+		s.WindowDays = 30
+	}
+	if s.WindowDays < 1 || s.WindowDays > 365 {
+		return s, errors.New("windowDays must be between 1 and 365")
+	}
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		return s, err
+	}
+	known := map[int]bool{}
+	for _, t := range targets {
+		known[t.ID] = true
+	}
+	for _, id := range s.TargetIDs {
+		if !known[id] {
+			return s, fmt.Errorf("unknown target %d", id)
+		}
+	}
+	return s, nil
+}
+
+// handleSLOs serves GET /slos, listing every SLO with its status, and POST
+// /slos, creating one.
+func handleSLOs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		slos, err := storage.ListSLOs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		now := clock.Now()
+		statuses := make([]SLOStatus, 0, len(slos))
+		for _, s := range slos {
+			st, err := computeSLOStatus(s, now)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			statuses = append(statuses, st)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statuses)
+	case http.MethodPost:
+		s, err := decodeSLO(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.ID = 0
+		if s.ID, err = storage.SaveSLO(s); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeSLO(w, s.ID, http.StatusCreated)
+	}
+}
+
+// handleSLO serves GET, PUT and DELETE /slos/{id}.
+func handleSLO(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeSLO(w, id, http.StatusOK)
+	case http.MethodPut:
+		s, err := decodeSLO(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.ID = id
+		if _, err := storage.SaveSLO(s); errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "slo not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeSLO(w, id, http.StatusOK)
+	case http.MethodDelete:
+		if err := storage.DeleteSLO(id); errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "slo not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeSLO(w http.ResponseWriter, id int64, code int) {
+	s, err := storage.GetSLO(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "slo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	st, err := computeSLOStatus(*s, clock.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,87 @@
+package server_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"uptime/server"
+	"uptime/server/servertest"
+)
+
+func sloStatus(t *testing.T, h *servertest.Harness, id int) server.SLOStatus {
+	t.Helper()
+	code, body := get(t, h.Client, h.Server.URL+"/api/slos/"+strconv.Itoa(id))
+	var st server.SLOStatus
+	if code != 200 || json.Unmarshal([]byte(body), &st) != nil {
+		t.Fatalf("GET /api/slos/%d: %d %s", id, code, body)
+	}
+	return st
+}
+
+// TestSLOBurnRateAlertWindowEdges follows a single failed check through the
+// burn-rate windows. With a 99.9% objective one bad check is enough to fire
+// both rules, so the alert starts in the pass that made the check and stops
+// in the pass where the check leaves the 30 minute window.
+func TestSLOBurnRateAlertWindowEdges(t *testing.T) {
+	h := servertest.New(t)
+	h.AddTarget("api", "stub://api", false)
+	post(t, h, "/api/slos", `{"name": "api", "objective": 99.9, "windowDays": 1}`)
+	h.Tick(9 * time.Minute)
+
+	h.SetResult("stub://api", false, "connection refused")
+	h.Tick(time.Minute)
+	h.SetResult("stub://api", true, "")
+	msgs := h.Messages()
+	if len(msgs) != 1 || !strings.HasPrefix(msgs[0], "🔥 SLO api") || !strings.Contains(msgs[0], "last 1h") {
+		t.Fatalf("messages after the failed check at 00:10: %q", msgs)
+	}
+	st := sloStatus(t, h, 1)
+	if !st.Alerting || st.BurnRates["5m"] == 0 {
+		t.Fatalf("status at 00:10: %+v", st)
+	}
+
+	// At 00:15 the check has left the 5m window, so the 1h rule stops; the
+	// 6h rule keeps firing until 00:40, when the 30m window is (00:10, 00:40].
+	h.Tick(29 * time.Minute)
+	st = sloStatus(t, h, 1)
+	if len(h.Messages()) != 1 || !st.Alerting || st.BurnRates["5m"] != 0 || st.BurnRates["30m"] == 0 {
+		t.Fatalf("at 00:39: messages %q, status %+v", h.Messages(), st)
+	}
+	h.Tick(time.Minute)
+	msgs = h.Messages()
+	if len(msgs) != 2 || msgs[1] != "✅ SLO api burn rate is back to normal" {
+		t.Fatalf("messages at 00:40: %q", msgs)
+	}
+	if st := sloStatus(t, h, 1); st.Alerting || st.BurnRates["30m"] != 0 || st.BurnRates["6h"] == 0 {
+		t.Fatalf("status at 00:40: %+v", st)
+	}
+}
+
+// TestSLOLatencyWithoutChecks covers a latency SLO whose target has no
+// checks in the window: it has no compliance or budget and never alerts,
+// however the other targets fare.
+func TestSLOLatencyWithoutChecks(t *testing.T) {
+	h := servertest.New(t)
+	h.AddTarget("api", "stub://api", false)
+	idle := h.AddTarget("idle", "stub://idle", false)
+	post(t, h, "/api/targets/"+strconv.Itoa(idle)+"/pause", `{}`)
+	post(t, h, "/api/slos", `{"name": "idle", "targetIds": [`+strconv.Itoa(idle)+`], "objective": 99, "latencyThresholdMs": 500, "windowDays": 1}`)
+	h.SetResult("stub://api", false, "connection refused")
+	h.Tick(10 * time.Minute)
+
+	st := sloStatus(t, h, 1)
+	if st.Checks != 0 || st.GoodChecks != 0 || st.Compliance != nil || st.ErrorBudgetRemaining != nil || st.Alerting {
+		t.Fatalf("status without checks: %+v", st)
+	}
+	for window, rate := range st.BurnRates {
+		if rate != 0 {
+			t.Errorf("burn rate over %s = %v, want 0", window, rate)
+		}
+	}
+	if msgs := h.Messages(); len(msgs) != 0 {
+		t.Fatalf("messages: %q", msgs)
+	}
+}
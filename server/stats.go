@@ -0,0 +1,251 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"uptime/storage"
+)
+
+// Stats summarizes a target's (or the whole fleet's) availability over a
+// window. Percent and mean values are nil when there is nothing to average.
+// Time in which a target was paused counts as neither up nor down.
+type Stats struct {
+	TargetID        int       `json:"targetId,omitempty"`
+	TargetName      string    `json:"targetName,omitempty"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	Checks          int       `json:"checks"`
+	FailedChecks    int       `json:"failedChecks"`
+	UptimePercent   *float64  `json:"uptimePercent"`
+	DowntimeMinutes float64   `json:"downtimeMinutes"`
+	PausedMinutes   float64   `json:"pausedMinutes"`
+	Incidents       int       `json:"incidents"`
+	// Recovered counts the incidents that ended inside the window, which
+	// are the ones MTTR averages over.
+	Recovered   int      `json:"recovered"`
+	MTTRSeconds *float64 `json:"mttrSeconds"`
+	MTBFSeconds *float64 `json:"mtbfSeconds"`
+	AvgMs       *float64 `json:"avgMs"`
+	P50Ms       *int64   `json:"p50Ms"`
+	P95Ms       *int64   `json:"p95Ms"`
+	P99Ms       *int64   `json:"p99Ms"`
+}
+
+// FleetStats is the response of /stats: totals over all targets plus the
+// per-target breakdown.
+type FleetStats struct {
+	Overall Stats   `json:"overall"`
+	Targets []Stats `json:"targets"`
+}
+
+// computeStats derives uptime from checks and downtime, MTTR and MTBF from
+// incidents overlapping [from, to). Downtime is clipped to the window and
+// excludes paused time, as does the window MTBF divides; MTTR averages the
+// full length of incidents that ended inside it. Paused targets are not
+// checked, so uptime excludes paused time by itself.
+func computeStats(target storage.TargetInfo, from, to time.Time) (*Stats, error) {
+	s := &Stats{TargetID: target.ID, TargetName: target.Name, From: from, To: to}
+
+	buckets, err := storage.CheckSeries(target.ID, from, to, to.Sub(from))
+	if err != nil {
+		return nil, err
+	}
+	if len(buckets) > 0 {
+		b := buckets[0]
+		s.Checks, s.FailedChecks = b.Count, b.Failures
+		s.AvgMs, s.P50Ms, s.P95Ms, s.P99Ms = b.AvgMs, b.P50Ms, b.P95Ms, b.P99Ms
+		if b.Uptime != nil {
+			pct := *b.Uptime * 100
+			s.UptimePercent = &pct
+		}
+	}
+
+	incidents, err := storage.ListIncidents(storage.IncidentQuery{TargetID: target.ID, From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	pauses, err := storage.PausePeriods(target.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var paused time.Duration
+	for _, p := range pauses {
+		paused += p.End.Sub(p.Start)
+	}
+
+	now := clock.Now()
+	var downtime, repair time.Duration
+	var repaired int
+	for _, inc := range incidents {
+		end := now
+		if inc.EndedAt != nil {
+			end = *inc.EndedAt
+			if !end.Before(from) && end.Before(to) {
+				repair += end.Sub(inc.StartedAt)
+				repaired++
+			}
+		}
+		start := inc.StartedAt
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			downtime += end.Sub(start)
+			for _, p := range pauses {
+				downtime -= overlap(start, end, p.Start, p.End)
+			}
+		}
+	}
+	s.Incidents = len(incidents)
+	s.Recovered = repaired
+	s.DowntimeMinutes = downtime.Minutes()
+	s.PausedMinutes = paused.Minutes()
+	if repaired > 0 {
+		mttr := (repair / time.Duration(repaired)).Seconds()
+		s.MTTRSeconds = &mttr
+	}
+	if s.Incidents > 0 {
+		mtbf := ((to.Sub(from) - paused - downtime) / time.Duration(s.Incidents)).Seconds()
+		s.MTBFSeconds = &mtbf
+	}
+	return s, nil
+}
+
+// overlap returns how long [aStart, aEnd) and [bStart, bEnd) overlap.
+func overlap(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	if bStart.After(aStart) {
+		aStart = bStart
+	}
+	if bEnd.Before(aEnd) {
+		aEnd = bEnd
+	}
+	if aEnd.After(aStart) {
+		return aEnd.Sub(aStart)
+	}
+	return 0
+}
+
+// parseWindow reads either window (24h, 7d, 30d, 90d or any Go duration,
+// ending now) or explicit from/to query parameters. The default window is
+// 24h.
+func parseWindow(r *http.Request) (from, to time.Time, err error) {
+	query := r.URL.Query()
+	if from, err = parseTimeParam(query.Get("from")); err != nil {
+		return from, to, fmt.Errorf("invalid from: %w", err)
+	}
+	if to, err = parseTimeParam(query.Get("to")); err != nil {
+		return from, to, fmt.Errorf("invalid to: %w", err)
+	}
+	if to.IsZero() {
+		to = clock.Now()
+	}
+	if from.IsZero() {
+		window := 24 * time.Hour
+		if s := query.Get("window"); s != "" {
+			if window, err = parseDays(s); err != nil || window <= 0 {
+				return from, to, fmt.Errorf("invalid window %q", s)
+			}
+		}
+		from = to.Add(-window)
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// parseDays is time.ParseDuration with support for a "d" (days) suffix.
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// handleTargetStats serves GET /targets/{id}/stats.
+func handleTargetStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	from, to, err := parseWindow(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := storage.GetTargetInfo(id)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	s, err := computeStats(*t, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// handleStats serves GET /stats for all targets.
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fleet := FleetStats{Overall: Stats{From: from, To: to}, Targets: []Stats{}}
+	var up int
+	var repair float64
+	var repaired int
+	for _, t := range targets {
+		s, err := computeStats(t, from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fleet.Targets = append(fleet.Targets, *s)
+		fleet.Overall.Checks += s.Checks
+		fleet.Overall.FailedChecks += s.FailedChecks
+		fleet.Overall.DowntimeMinutes += s.DowntimeMinutes
+		fleet.Overall.PausedMinutes += s.PausedMinutes
+		fleet.Overall.Incidents += s.Incidents
+		up += s.Checks - s.FailedChecks
+		fleet.Overall.Recovered += s.Recovered
+		if s.MTTRSeconds != nil {
+			// Weight by recoveries so the fleet MTTR is the mean over all
+			// incidents rather than over targets.
+			repair += *s.MTTRSeconds * float64(s.Recovered)
+			repaired += s.Recovered
+		}
+	}
+	if fleet.Overall.Checks > 0 {
+		pct := float64(up) / float64(fleet.Overall.Checks) * 100
+		fleet.Overall.UptimePercent = &pct
+	}
+	if repaired > 0 {
+		mttr := repair / float64(repaired)
+		fleet.Overall.MTTRSeconds = &mttr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fleet)
+}
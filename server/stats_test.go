@@ -0,0 +1,113 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"uptime/server"
+	"uptime/server/servertest"
+)
+
+func TestTargetStats(t *testing.T) {
+	h := servertest.New(t)
+	id := h.AddTarget("api", "stub://api", false)
+	path := "/api/targets/" + strconv.Itoa(id)
+
+	// Checked every minute from 00:01: down from 00:11 to 00:21, paused
+	// from 00:30 to 01:00, down again from 01:00 on and paused from 01:05
+	// to 01:25 in the middle of that outage. The window ends at 01:30.
+	h.Tick(10 * time.Minute)
+	h.SetResult("stub://api", false, "down")
+	h.Tick(10 * time.Minute)
+	h.SetResult("stub://api", true, "")
+	h.Tick(10 * time.Minute)
+	post(t, h, path+"/pause", "")
+	h.Tick(30 * time.Minute)
+	h.SetResult("stub://api", false, "down")
+	post(t, h, path+"/resume", "")
+	h.Tick(5 * time.Minute)
+	post(t, h, path+"/pause", "")
+	h.Tick(20 * time.Minute)
+	post(t, h, path+"/resume", "")
+	h.Tick(5 * time.Minute)
+
+	ptr := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		name      string
+		query     string
+		from      string
+		incidents int
+		recovered int
+		downtime  float64
+		paused    float64
+		mttr      *float64
+		mtbf      *float64
+	}{
+		// MTBF is (120m - 50m paused - 20m down) / 2.
+		{"whole timeline", "window=2h", "2023-12-31T23:30:00Z", 2, 1, 20, 50, ptr(600), ptr(1500)},
+		{"days", "window=1d", "2023-12-31T01:30:00Z", 2, 1, 20, 50, ptr(600), ptr(41100)},
+		// Both outages and both pauses are clipped to the window.
+		{"clipped", "from=2024-01-01T00:15:00Z&to=2024-01-01T01:15:00Z", "2024-01-01T00:15:00Z", 2, 1, 6 + 5, 30 + 10, ptr(600), ptr(270)},
+		// MTTR counts the full length of an outage ending in the window.
+		{"outage ending inside", "from=2024-01-01T00:20:00Z&to=2024-01-01T00:25:00Z", "2024-01-01T00:20:00Z", 1, 1, 1, 0, ptr(600), ptr(240)},
+		{"ending as an outage starts", "from=2024-01-01T00:05:00Z&to=2024-01-01T00:11:00Z", "2024-01-01T00:05:00Z", 0, 0, 0, 0, nil, nil},
+		// Windows are half-open: a recovery at from lies inside.
+		{"starting as an outage ends", "from=2024-01-01T00:21:00Z&to=2024-01-01T00:30:00Z", "2024-01-01T00:21:00Z", 1, 1, 0, 0, ptr(600), ptr(540)},
+		{"paused throughout", "from=2024-01-01T00:35:00Z&to=2024-01-01T00:55:00Z", "2024-01-01T00:35:00Z", 0, 0, 0, 20, nil, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := get(t, h.Client, h.Server.URL+path+"/stats?"+tc.query)
+			var s server.Stats
+			if code != http.StatusOK || json.Unmarshal([]byte(body), &s) != nil {
+				t.Fatalf("%d %s", code, body)
+			}
+			if got := s.From.Format(time.RFC3339); got != tc.from {
+				t.Errorf("from = %s, want %s", got, tc.from)
+			}
+			if s.Incidents != tc.incidents || s.Recovered != tc.recovered || s.DowntimeMinutes != tc.downtime || s.PausedMinutes != tc.paused {
+				t.Errorf("incidents %d, recovered %d, downtime %vm, paused %vm; want %d, %d, %vm, %vm",
+					s.Incidents, s.Recovered, s.DowntimeMinutes, s.PausedMinutes, tc.incidents, tc.recovered, tc.downtime, tc.paused)
+			}
+			for _, v := range []struct {
+				name      string
+				got, want *float64
+			}{{"MTTR", s.MTTRSeconds, tc.mttr}, {"MTBF", s.MTBFSeconds, tc.mtbf}} {
+				if (v.got == nil) != (v.want == nil) || (v.got != nil && *v.got != *v.want) {
+					t.Errorf("%s = %v, want %v", v.name, fmtPtr(v.got), fmtPtr(v.want))
+				}
+			}
+		})
+	}
+}
+
+func fmtPtr(v *float64) string {
+	if v == nil {
+		return "nil"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func TestTargetStatsErrors(t *testing.T) {
+	h := servertest.New(t)
+	id := h.AddTarget("api", "stub://api", false)
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/api/targets/" + strconv.Itoa(id+1) + "/stats", http.StatusNotFound},
+		{"/api/targets/x/stats", http.StatusBadRequest},
+		{"/api/targets/" + strconv.Itoa(id) + "/stats?window=1.5d", http.StatusBadRequest},
+		{"/api/targets/" + strconv.Itoa(id) + "/stats?window=0d", http.StatusBadRequest},
+		{"/api/targets/" + strconv.Itoa(id) + "/stats?window=-2h", http.StatusBadRequest},
+		{"/api/targets/" + strconv.Itoa(id) + "/stats?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", http.StatusBadRequest},
+	} {
+		code, body := get(t, h.Client, h.Server.URL+tc.path)
+		var apiErr server.APIError
+		if code != tc.code || json.Unmarshal([]byte(body), &apiErr) != nil || apiErr.Error == "" {
+			t.Errorf("GET %s: %d %s, want %d with a JSON error", tc.path, code, body, tc.code)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"uptime/storage"
+)
+
+const maxTargetName = 200
+
+// checkTimeout bounds manual checks, which block the request. It is above
+// the probes' own timeouts so it only cuts off probes that hang.
+const checkTimeout = 30 * time.Second
+
+// APIError is the JSON body of error responses from the target endpoints.
+// Fields lists the offending request fields when validation failed.
+type APIError struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError explains why one request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, status int, msg string, fields ...FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{Error: msg, Fields: fields})
+}
+
+// writeStorageError maps storage.ErrNotFound to 404 and anything else to 500.
+func writeStorageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "target not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// TargetRequest is the body of POST and PUT /targets. ID is only used by
+// PUT; an empty password keeps the stored one on update.
+type TargetRequest struct {
+	ID       int    `json:"id,omitempty"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// TargetPatch is the body of PATCH /targets/{id}; absent fields are
+// unchanged.
+type TargetPatch struct {
+	Name       *string `json:"name,omitempty"`
+	URL        *string `json:"url,omitempty"`
+	Type       *string `json:"type,omitempty"`
+	Username   *string `json:"username,omitempty"`
+	Password   *string `json:"password,omitempty"`
+	Subscribed *bool   `json:"subscribed,omitempty"`
+}
+
+// validateTarget checks a target's name, type and URL. The name must be
+// unique among targets other than id, because imports and the config file
+// match targets by name.
+func validateTarget(id int, name, rawURL, typ string) ([]FieldError, error) {
+	errs := validateTargetSpec(name, rawURL, typ)
+	if len(errs) > 0 && errs[0].Field == "name" {
+		return errs, nil
+	}
+	targets, err := storage.GetTargetInfos()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range targets {
+		if t.Name == name && t.ID != id {
+			errs = append([]FieldError{{"name", "is already used by target " + strconv.Itoa(t.ID)}}, errs...)
+			break
+		}
+	}
+	return errs, nil
+}
+
+// validateTargetSpec is validateTarget without the check for a unique name.
+func validateTargetSpec(name, rawURL, typ string) []FieldError {
+	var errs []FieldError
+	switch {
+	case strings.TrimSpace(name) == "":
+		errs = append(errs, FieldError{"name", "is required"})
+	case name != strings.TrimSpace(name):
+		errs = append(errs, FieldError{"name", "must not start or end with spaces"})
+	case len(name) > maxTargetName:
+		errs = append(errs, FieldError{"name", "must be at most " + strconv.Itoa(maxTargetName) + " characters"})
+	}
+	if typ == "" {
+		errs = append(errs, FieldError{"type", "is required"})
+	} else if !storage.IsProbeType(typ) {
+		errs = append(errs, FieldError{"type", "must be one of " + strings.Join(storage.ProbeTypes(), ", ")})
+	} else if msg := targetURLError(typ, rawURL); msg != "" {
+		errs = append(errs, FieldError{"url", msg})
+	}
+	return errs
+}
+
+// targetURLError describes what is wrong with the address of a target of
+// type typ, or returns "". HTTP targets take a URL, postgres targets
+// host:port with an optional /database and ?parameters, and redis targets
+// host:port. Other (registered) types only need a non-empty address.
+func targetURLError(typ, rawURL string) string {
+	if rawURL == "" {
+		return "is required"
+	}
+	switch typ {
+	case "http":
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an http:// or https:// URL"
+		}
+	case "postgres":
+		addr, _, _ := strings.Cut(rawURL, "?")
+		addr, _, _ = strings.Cut(addr, "/")
+		return hostPortError(addr)
+	case "redis":
+		return hostPortError(rawURL)
+	}
+	return ""
+}
+
+func hostPortError(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return "must be host:port"
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "must have a port between 1 and 65535"
+	}
+	return ""
+}
+
+// writeTarget responds with the current state of target id.
+func writeTarget(w http.ResponseWriter, status, id int) {
+	t, err := storage.GetTargetInfo(id)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleTarget serves GET, PATCH and DELETE /targets/{id}. PATCH changes
+// only the fields present in the body; an empty password keeps the stored
+// one.
+func handleTarget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeTarget(w, http.StatusOK, id)
+	case http.MethodPatch:
+		cur, err := storage.GetTargetInfo(id)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		if cur.Managed {
+			writeError(w, http.StatusConflict, storage.ErrManaged.Error())
+			return
+		}
+		var patch TargetPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		name, rawURL, typ, username, password := cur.Name, cur.URL, cur.Type, cur.Username, ""
+		if patch.Name != nil {
+			name = *patch.Name
+		}
+		if patch.URL != nil {
+			rawURL = *patch.URL
+		}
+		if patch.Type != nil {
+			typ = *patch.Type
+		}
+		if patch.Username != nil {
+			username = *patch.Username
+		}
+		if patch.Password != nil {
+			password = *patch.Password
+		}
+		fields, err := validateTarget(id, name, rawURL, typ)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(fields) > 0 {
+			writeError(w, http.StatusBadRequest, "invalid target", fields...)
+			return
+		}
+		if err := storage.UpdateTarget(id, name, rawURL, typ, username, password); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		if patch.Subscribed != nil {
+			subscribe := storage.UnsubscribeTarget
+			if *patch.Subscribed {
+				subscribe = storage.SubscribeTarget
+			}
+			if err := subscribe(id); err != nil {
+				writeStorageError(w, err)
+				return
+			}
+		}
+		ResetMonitorLoop()
+		writeTarget(w, http.StatusOK, id)
+	case http.MethodDelete:
+		if rejectManaged(w, id) {
+			return
+		}
+		if err := storage.DeleteTarget(id, clock.Now()); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleCheckTarget serves POST /targets/{id}/check: it checks the target
+// now instead of waiting for the next monitor pass and returns the result.
+func handleCheckTarget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	res, err := checkTarget(id, checkTimeout)
+	if errors.Is(err, errPaused) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -1,48 +1,159 @@
 package server
 
 import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"time"
+
+	"uptime/secrets"
+	"uptime/storage"
 )
 
-var lastSent = make(map[string]time.Time)
+// sendMessage delivers a notification. It defaults to Telegram and can be
+// replaced with SetNotifier, e.g. to capture messages in tests.
+var sendMessage = sendTelegram
+
+// SetNotifier replaces the function used to deliver notifications. Passing nil
+// restores the Telegram sender.
+func SetNotifier(fn func(msg string) error) {
+	if fn == nil {
+		fn = sendTelegram
+	}
+	sendMessage = fn
+}
+
+// Attachment is a document delivered along with a notification.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// sendReport delivers a notification with an attached document. Like
+// sendMessage it defaults to Telegram and can be replaced with
+// SetReportNotifier.
+var sendReport = sendTelegramReport
+
+// SetReportNotifier replaces the function used to deliver reports. Passing
+// nil restores the Telegram sender.
+func SetReportNotifier(fn func(msg string, att Attachment) error) {
+	if fn == nil {
+		fn = sendTelegramReport
+	}
+	sendReport = fn
+}
 
 func sendTelegram(msg string) error {
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	chatID := os.Getenv("TELEGRAM_CHAT_ID")
+	token, chatID, err := telegramCredentials()
+	if err != nil {
+		return err
+	}
 	if token == "" || chatID == "" {
 		return nil
 	}
 	data := url.Values{}
 	data.Set("chat_id", chatID)
 	data.Set("text", msg)
-	_, err := http.PostForm("https://api.telegram.org/bot"+token+"/sendMessage", data)
+	_, err = http.PostForm("https://api.telegram.org/bot"+token+"/sendMessage", data)
 	return err
 }
 
-func notifyDown(resource string) {
-	now := time.Now()
-	if t, ok := lastSent[resource]; ok {
-		if now.Sub(t) < 24*time.Hour && now.Day() == t.Day() {
+// telegramCaptionLimit is the longest caption Telegram accepts on a document.
+const telegramCaptionLimit = 1024
+
+// sendTelegramReport sends the attachment as a document with msg as its
+// caption. Both go in one request, so a failed delivery can be retried
+// without repeating half of it. Captions over Telegram's limit are cut; the
+// document holds every row.
+func sendTelegramReport(msg string, att Attachment) error {
+	token, chatID, err := telegramCredentials()
+	if err != nil {
+		return err
+	}
+	if token == "" || chatID == "" {
+		return nil
+	}
+	if r := []rune(msg); len(r) > telegramCaptionLimit {
+		msg = string(r[:telegramCaptionLimit-1]) + "…"
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", chatID)
+	w.WriteField("caption", msg)
+	part, err := w.CreateFormFile("document", att.Name)
+	if err != nil {
+		return err
+	}
+	part.Write(att.Data)
+	if err := w.Close(); err != nil {
+		return err
+	}
+	resp, err := http.Post("https://api.telegram.org/bot"+token+"/sendDocument", w.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram sendDocument: %s", resp.Status)
+	}
+	return nil
+}
+
+// currentTelegramConfig returns the Telegram notifier declared in the config
+// file, reporting it as managed, or else the one stored by an import. Nil
+// means the environment's.
+func currentTelegramConfig() (cfg *TelegramConfig, managed bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if telegramConfig != nil {
+		return telegramConfig, true
+	}
+	return storedTelegram, false
+}
+
+// telegramCredentials returns the bot token and chat ID from the config file
+// if it declares them, then from an import, otherwise from the environment.
+func telegramCredentials() (token, chatID string, err error) {
+	cfg, _ := currentTelegramConfig()
+	if cfg == nil {
+		return os.Getenv("TELEGRAM_BOT_TOKEN"), os.Getenv("TELEGRAM_CHAT_ID"), nil
+	}
+	if token, err = secrets.Resolve(cfg.BotToken); err != nil {
+		return "", "", err
+	}
+	if chatID, err = secrets.Resolve(cfg.ChatID); err != nil {
+		return "", "", err
+	}
+	return token, chatID, nil
+}
+
+// notifyDown sends a down notification unless one was already sent for the
+// target earlier the same day.
+func notifyDown(resource string, st *storage.TargetState) {
+	now := clock.Now()
+	if t := st.LastNotifiedAt; t != nil {
+		if now.Sub(*t) < 24*time.Hour && now.Day() == t.Day() {
 			return
 		}
 	}
-	if err := sendTelegram("🚨 Resource down: " + resource); err == nil {
-		lastSent[resource] = now
+	if err := sendMessage("🚨 Resource down: " + resource); err == nil {
+		st.LastNotifiedAt = &now
 	}
 }
 
-func notifyUp(resource string) {
-	if err := sendTelegram("✅ Resource back up: " + resource); err == nil {
-		// Clear the 'lastSent' timestamp for this resource.
+func notifyUp(resource string, st *storage.TargetState) {
+	if err := sendMessage("✅ Resource back up: " + resource); err == nil {
+		// Clear the last notification time for this resource.
 		// This is important so that if it goes down again, a new
 		// 'down' notification can be sent immediately.
-		delete(lastSent, resource)
+		st.LastNotifiedAt = nil
 	}
 }
 
 func testTelegram() {
-	_ = sendTelegram("Test notification from Uptime Monitor")
+	_ = sendMessage("Test notification from Uptime Monitor")
 }
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"uptime/storage"
+)
+
+// TokenResponse is returned when a token is created. Token is the secret to
+// send as "Authorization: Bearer <token>"; it is not shown again.
+type TokenResponse struct {
+	storage.APIToken
+	Token string `json:"token"`
+}
+
+// TokenRequest is the body of POST /tokens. ExpiresIn is a duration such
+// as "12h" or "30d" and takes precedence over ExpiresAt.
+type TokenRequest struct {
+	Name      string     `json:"name"`
+	Scope     string     `json:"scope,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	ExpiresIn string     `json:"expiresIn,omitempty"`
+}
+
+// handleTokens serves GET /tokens, listing the caller's API tokens (every
+// user's for admins), and POST
+// /tokens with {"name": "...", "scope": "read" or "write", "expiresAt":
+// RFC 3339 or "expiresIn": "30d"}. Tokens without an expiry never expire.
+func handleTokens(w http.ResponseWriter, r *http.Request) {
+	p := principal(r)
+	switch r.Method {
+	case http.MethodGet:
+		owner := p.UserID
+		if p.Role == storage.RoleAdmin {
+			owner = 0
+		}
+		tokens, err := storage.ListTokens(owner)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	case http.MethodPost:
+		var body TokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if body.Scope == "" {
+			body.Scope = storage.ScopeRead
+		}
+		if body.Scope != storage.ScopeRead && body.Scope != storage.ScopeWrite {
+			http.Error(w, "scope must be read or write", http.StatusBadRequest)
+			return
+		}
+		// A read-only token could not get here, but keep tokens from
+		// minting anything broader than themselves regardless.
+		if p.TokenID != 0 && p.Scope != storage.ScopeWrite && body.Scope == storage.ScopeWrite {
+			http.Error(w, "token is read-only", http.StatusForbidden)
+			return
+		}
+		now := clock.Now()
+		if body.ExpiresIn != "" {
+			d, err := parseDays(body.ExpiresIn)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid expiresIn", http.StatusBadRequest)
+				return
+			}
+			expires := now.Add(d)
+			body.ExpiresAt = &expires
+		}
+		if body.ExpiresAt != nil && !body.ExpiresAt.After(now) {
+			http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+			return
+		}
+		t, secret, err := storage.CreateToken(p.UserID, body.Name, body.Scope, now, body.ExpiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(TokenResponse{APIToken: *t, Token: secret})
+	}
+}
+
+// handleRevokeToken serves DELETE /tokens/{id} for one of the caller's
+// tokens, or any token for admins.
+func handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	owner := principal(r).UserID
+	if principal(r).Role == storage.RoleAdmin {
+		owner = 0
+	}
+	if err := storage.RevokeToken(id, owner); errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"uptime/storage"
+)
+
+// UserRequest is the body of POST /users. Role defaults to viewer.
+type UserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
+}
+
+// UserPatch is the body of PATCH /users/{id}; absent fields are unchanged.
+type UserPatch struct {
+	Role     *string `json:"role,omitempty"`
+	Password *string `json:"password,omitempty"`
+}
+
+// handleUsers serves GET /users and POST /users with {"username": "...",
+// "password": "...", "role": "viewer", "operator" or "admin"}.
+func handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users, err := storage.ListUsers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+	case http.MethodPost:
+		var body UserRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Role == "" {
+			body.Role = storage.RoleViewer
+		}
+		if !storage.IsRole(body.Role) {
+			http.Error(w, "role must be viewer, operator or admin", http.StatusBadRequest)
+			return
+		}
+		if len(body.Password) < minPasswordLength {
+			http.Error(w, "password is too short", http.StatusBadRequest)
+			return
+		}
+		user, err := storage.CreateUser(body.Username, body.Password, body.Role, clock.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// handleUser serves PATCH /users/{id} with {"role": "..."} and/or
+// {"password": "..."}, and DELETE /users/{id}. The last admin can be neither
+// demoted nor deleted.
+func handleUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		var body UserPatch
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Role != nil && !storage.IsRole(*body.Role) {
+			http.Error(w, "role must be viewer, operator or admin", http.StatusBadRequest)
+			return
+		}
+		if body.Password != nil && len(*body.Password) < minPasswordLength {
+			http.Error(w, "password is too short", http.StatusBadRequest)
+			return
+		}
+		if body.Role != nil {
+			if err := storage.SetRole(id, *body.Role); err != nil {
+				writeUserError(w, err)
+				return
+			}
+		}
+		if body.Password != nil {
+			if err := storage.SetPassword(id, *body.Password); err != nil {
+				writeUserError(w, err)
+				return
+			}
+		}
+		user, err := storage.GetUser(id)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	case http.MethodDelete:
+		if err := storage.DeleteUser(id); err != nil {
+			writeUserError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrLastAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AuditEntry records one change: who made it, from where, and the state of
+// the changed resource before and after as JSON. Changes maps every
+// top-level field that differs to its [before, after] values.
+type AuditEntry struct {
+	ID       int64           `json:"id"`
+	At       time.Time       `json:"at"`
+	Actor    string          `json:"actor"`
+	Role     string          `json:"role,omitempty"`
+	TokenID  int64           `json:"tokenId,omitempty"`
+	SourceIP string          `json:"sourceIp,omitempty"`
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Status   int             `json:"status"`
+	Resource string          `json:"resource,omitempty"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	After    json.RawMessage `json:"after,omitempty"`
+	Changes  json.RawMessage `json:"changes,omitempty"`
+}
+
+// AuditQuery filters ListAudit. Zero values mean no filter; Resource matches
+// exactly or as a prefix ending in ":" (e.g. "target:"). Before is a cursor:
+// only entries with a smaller ID are returned.
+type AuditQuery struct {
+	Actor    string
+	Resource string
+	From, To time.Time
+	Before   int64
+	Limit    int
+}
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+func createAuditSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS audit_log (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                at DATETIME,
+                actor TEXT,
+                role TEXT,
+                token_id INTEGER,
+                source_ip TEXT,
+                method TEXT,
+                path TEXT,
+                status INTEGER,
+                resource TEXT,
+                before TEXT,
+                after TEXT,
+                changes TEXT
+        );
+        CREATE INDEX IF NOT EXISTS idx_audit_log_at ON audit_log(at);
+        CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON audit_log(resource);
+        `)
+	return err
+}
+
+// nullJSON stores empty JSON as NULL.
+func nullJSON(b json.RawMessage) any {
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+	return string(b)
+}
+
+func AddAuditEntry(e AuditEntry) error {
+	_, err := db.Exec(`INSERT INTO audit_log(at, actor, role, token_id, source_ip, method, path, status, resource, before, after, changes)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.At.UTC(), e.Actor, e.Role, e.TokenID, e.SourceIP, e.Method, e.Path, e.Status, e.Resource,
+		nullJSON(e.Before), nullJSON(e.After), nullJSON(e.Changes))
+	return err
+}
+
+// ListAudit returns audit entries newest first.
+func ListAudit(q AuditQuery) ([]AuditEntry, error) {
+	var where []string
+	var args []any
+	if q.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, q.Actor)
+	}
+	if q.Resource != "" {
+		if strings.HasSuffix(q.Resource, ":") {
+			where = append(where, "substr(resource, 1, ?) = ?")
+			args = append(args, len(q.Resource), q.Resource)
+		} else {
+			where = append(where, "resource = ?")
+			args = append(args, q.Resource)
+		}
+	}
+	if !q.From.IsZero() {
+		where = append(where, "at >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "at < ?")
+		args = append(args, q.To.UTC())
+	}
+	if q.Before > 0 {
+		where = append(where, "id < ?")
+		args = append(args, q.Before)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultAuditLimit
+	}
+	if q.Limit > MaxAuditLimit {
+		q.Limit = MaxAuditLimit
+	}
+	query := `SELECT id, at, actor, role, token_id, source_ip, method, path, status, resource, before, after, changes FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after, changes sql.NullString
+		if err := rows.Scan(&e.ID, &e.At, &e.Actor, &e.Role, &e.TokenID, &e.SourceIP, &e.Method, &e.Path, &e.Status,
+			&e.Resource, &before, &after, &changes); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		if changes.Valid {
+			e.Changes = json.RawMessage(changes.String)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
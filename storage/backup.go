@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const backupPrefix = "monitor-"
+
+// BackupInfo describes a backup file.
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Backup writes a consistent snapshot of the live database into dir using
+// VACUUM INTO, then deletes all but the newest keep backups (keep <= 0 keeps
+// everything).
+func Backup(dir string, keep int) (*BackupInfo, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	// Make sure queued checks are part of the snapshot.
+	if err := Flush(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name := backupPrefix + now.Format("20060102T150405.000Z") + ".db"
+	path := filepath.Join(dir, name)
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if keep > 0 {
+		if err := pruneBackups(dir, keep); err != nil {
+			return nil, err
+		}
+	}
+	return &BackupInfo{Name: name, Path: path, Size: fi.Size(), CreatedAt: now}, nil
+}
+
+// ListBackups returns the backups in dir, newest first.
+func ListBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []BackupInfo
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, BackupInfo{Name: name, Path: filepath.Join(dir, name), Size: fi.Size(), CreatedAt: fi.ModTime().UTC()})
+	}
+	// Names embed the UTC timestamp, so they sort chronologically.
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+func pruneBackups(dir string, keep int) error {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateBackup checks that path is a monitor database this version can
+// open: it must contain the core tables and a schema version no newer than
+// SchemaVersion.
+func ValidateBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("not a monitor database: %w", err)
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("backup schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+	for _, table := range []string{"checks", "targets", "settings"} {
+		var n int
+		if err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("not a monitor database: missing table %s", table)
+		}
+	}
+	var result string
+	if err := conn.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup is corrupt: %s", result)
+	}
+	return nil
+}
+
+// ErrDatabaseInUse is returned by Restore while the database is open, for
+// example by a running server.
+var ErrDatabaseInUse = errors.New("database is in use: stop the server before restoring")
+
+// rename is os.Rename; tests replace it to make a restore fail halfway.
+var rename = os.Rename
+
+// Restore validates the backup at src and swaps it in as the database file
+// dst. It refuses to while dst is open, so the server must be stopped. The
+// previous database is kept next to dst with a .pre-restore suffix; if the
+// swap fails, it is put back.
+func Restore(src, dst string) error {
+	if err := ValidateBackup(src); err != nil {
+		return err
+	}
+	if err := checkNotInUse(dst); err != nil {
+		return err
+	}
+
+	tmp := dst + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Move the old database together with its WAL files, which must not be
+	// replayed onto the restored one.
+	var moved []string
+	undo := func() {
+		for _, suffix := range moved {
+			rename(dst+".pre-restore"+suffix, dst+suffix)
+		}
+		os.Remove(tmp)
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := rename(dst+suffix, dst+".pre-restore"+suffix)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			undo()
+			return err
+		}
+		moved = append(moved, suffix)
+	}
+	if err := rename(tmp, dst); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+// checkNotInUse returns ErrDatabaseInUse if another connection has the
+// database at path open. Leaving WAL mode needs the database to itself, so
+// it fails while the server runs; otherwise it also folds a WAL left behind
+// by a crash into the file kept as .pre-restore.
+func checkNotInUse(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	conn, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=0")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var mode string
+	err = conn.QueryRow(`PRAGMA journal_mode=DELETE`).Scan(&mode)
+	var se sqlite3.Error
+	if errors.As(err, &se) && (se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked) {
+		return ErrDatabaseInUse
+	}
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
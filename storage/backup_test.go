@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backupWithTarget writes a database with one target named name to a
+// backup in dir and returns the backup. The database is closed afterwards.
+func backupWithTarget(t *testing.T, dir, name string) *BackupInfo {
+	t.Helper()
+	if err := Open(filepath.Join(t.TempDir(), "uptime.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if _, err := AddTarget(name, "https://"+name+".example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Backup(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// targetNames opens the database at path and returns its target names.
+func targetNames(t *testing.T, path string) []string {
+	t.Helper()
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	targets, err := GetTargetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tr := range targets {
+		names = append(names, tr.Name)
+	}
+	return names
+}
+
+func TestBackupKeepsNewest(t *testing.T) {
+	if err := Open(filepath.Join(t.TempDir(), "uptime.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	dir := t.TempDir()
+	var names []string
+	for i := 0; i < 4; i++ {
+		b, err := Backup(dir, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, b.Name)
+		// Names have millisecond resolution.
+		time.Sleep(2 * time.Millisecond)
+	}
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Name != names[3] || backups[1].Name != names[2] {
+		t.Fatalf("kept %+v, want the newest two of %q", backups, names)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	b := backupWithTarget(t, t.TempDir(), "backed-up")
+	dst := filepath.Join(t.TempDir(), "uptime.db")
+	if err := Open(dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTarget("current", "https://current.example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(b.Path, dst); !errors.Is(err, ErrDatabaseInUse) {
+		t.Fatalf("restore onto an open database: %v, want ErrDatabaseInUse", err)
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(b.Path, dst); err != nil {
+		t.Fatal(err)
+	}
+	if names := targetNames(t, dst); len(names) != 1 || names[0] != "backed-up" {
+		t.Fatalf("targets after restore: %q", names)
+	}
+	if names := targetNames(t, dst+".pre-restore"); len(names) != 1 || names[0] != "current" {
+		t.Fatalf("targets of the previous database: %q", names)
+	}
+}
+
+func TestRestoreRejectsInvalidBackups(t *testing.T) {
+	dir := t.TempDir()
+	valid := backupWithTarget(t, t.TempDir(), "api")
+	data, err := os.ReadFile(valid.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// Everything after the first page, which holds the schema, overwritten.
+	corrupt := append([]byte(nil), data...)
+	for i := 4096; i < len(corrupt); i++ {
+		corrupt[i] = 0xff
+	}
+	other := filepath.Join(dir, "other.db")
+	if err := Open(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DROP TABLE checks`); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	dst := filepath.Join(t.TempDir(), "uptime.db")
+	if err := Open(dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTarget("current", "https://current.example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	for name, src := range map[string]string{
+		"missing":    filepath.Join(dir, "missing.db"),
+		"not sqlite": write("text.db", []byte("not a database")),
+		"corrupt":    write("corrupt.db", corrupt),
+		"other":      other,
+	} {
+		if err := Restore(src, dst); err == nil {
+			t.Errorf("restore of a %s backup succeeded", name)
+		}
+	}
+	if names := targetNames(t, dst); len(names) != 1 || names[0] != "current" {
+		t.Fatalf("targets after rejected restores: %q", names)
+	}
+}
+
+func TestRestoreFailureKeepsDatabase(t *testing.T) {
+	b := backupWithTarget(t, t.TempDir(), "backed-up")
+	dst := filepath.Join(t.TempDir(), "uptime.db")
+	if err := Open(dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTarget("current", "https://current.example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	// The backup copy cannot be moved into place.
+	rename = func(from, to string) error {
+		if from == dst+".restore" {
+			return errors.New("disk on fire")
+		}
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+	if err := Restore(b.Path, dst); err == nil {
+		t.Fatal("restore succeeded")
+	}
+	for _, name := range []string{dst + ".restore", dst + ".pre-restore"} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind: %v", filepath.Base(name), err)
+		}
+	}
+	if names := targetNames(t, dst); len(names) != 1 || names[0] != "current" {
+		t.Fatalf("targets after a failed restore: %q", names)
+	}
+}
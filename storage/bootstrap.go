@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const initializedKey = "initialized_at"
+
+// exampleTargets are inserted on first run when seeding is requested.
+var exampleTargets = []TargetSpec{
+	{Name: "Google", URL: "https://www.google.com", Type: "http"},
+	{Name: "GitHub", URL: "https://github.com", Type: "http"},
+}
+
+// Bootstrap runs first-run initialization exactly once per database. If
+// seedExamples is set and the database has no targets yet, a few example
+// targets are added. Afterwards the database is marked as initialized, so
+// deleting every target never brings the examples back.
+func Bootstrap(seedExamples bool) error {
+	initialized, err := IsInitialized()
+	if err != nil || initialized {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if seedExamples {
+		var n int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM targets").Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			for _, t := range exampleTargets {
+				if _, err := tx.Exec("INSERT INTO targets(name, url, type, username, password, subscribed) VALUES(?, ?, ?, '', '', 0)", t.Name, t.URL, t.Type); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO meta(key, value) VALUES(?, ?)", initializedKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsInitialized reports whether Bootstrap has run on this database.
+func IsInitialized() (bool, error) {
+	var v string
+	err := db.QueryRow("SELECT value FROM meta WHERE key = ?", initializedKey).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// bootstrapNames opens the database at path, bootstraps it and returns the
+// names of its targets.
+func bootstrapNames(t *testing.T, path string, seedExamples bool) []string {
+	t.Helper()
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if err := Bootstrap(seedExamples); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsInitialized(); err != nil || !ok {
+		t.Fatalf("IsInitialized after Bootstrap: %v, %v", ok, err)
+	}
+	targets, err := GetTargetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tr := range targets {
+		names = append(names, tr.Name)
+	}
+	return names
+}
+
+func TestBootstrapSeedsOnlyWhenAsked(t *testing.T) {
+	if names := bootstrapNames(t, filepath.Join(t.TempDir(), "uptime.db"), false); len(names) != 0 {
+		t.Fatalf("seeded %q without SeedExamples", names)
+	}
+	names := bootstrapNames(t, filepath.Join(t.TempDir(), "uptime.db"), true)
+	var want []string
+	for _, t := range exampleTargets {
+		want = append(want, t.Name)
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("seeded %q, want %q", names, want)
+	}
+}
+
+func TestBootstrapDoesNotReseed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uptime.db")
+	bootstrapNames(t, path, true)
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := GetTargetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range targets {
+		if err := DeleteTarget(tr.ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Close()
+
+	if names := bootstrapNames(t, path, true); len(names) != 0 {
+		t.Fatalf("second start seeded %q", names)
+	}
+}
+
+func TestBootstrapKeepsExistingTargets(t *testing.T) {
+	// A database from before the initialized marker: it has targets but was
+	// never bootstrapped.
+	path := filepath.Join(t.TempDir(), "uptime.db")
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTarget("api", "https://api.example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsInitialized(); err != nil || ok {
+		t.Fatalf("IsInitialized before Bootstrap: %v, %v", ok, err)
+	}
+	Close()
+
+	if names := bootstrapNames(t, path, true); !reflect.DeepEqual(names, []string{"api"}) {
+		t.Fatalf("targets %q, want [api]", names)
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"uptime/probes"
+)
+
+const (
+	DefaultCheckLimit = 100
+	MaxCheckLimit     = 1000
+)
+
+// CheckRecord is a stored check result together with its row and target IDs.
+type CheckRecord struct {
+	ID       int64
+	TargetID int
+	probes.Result
+}
+
+// CheckQuery selects a page of one target's check history. Zero values mean
+// "no bound"; Status filters on up (true) or down (false) when set. Results are
+// returned newest first and After is the cursor of the previous page.
+type CheckQuery struct {
+	TargetID int
+	From     time.Time
+	To       time.Time
+	Status   *bool
+	Limit    int
+	After    *CheckCursor
+}
+
+// CheckCursor is the position of the last record of a page. It holds the
+// record's sort key rather than only its ID, so the next page is found even
+// if that record has been deleted since.
+type CheckCursor struct {
+	CheckedAt time.Time
+	ID        int64
+}
+
+// QueryChecks returns one page of checks matching q, and the cursor for the
+// next page (nil when there are no more rows).
+func QueryChecks(q CheckQuery) ([]CheckRecord, *CheckCursor, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultCheckLimit
+	}
+	if limit > MaxCheckLimit {
+		limit = MaxCheckLimit
+	}
+
+	where := []string{"target_id = ?"}
+	args := []any{q.TargetID}
+	if !q.From.IsZero() {
+		where = append(where, "checked_at >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "checked_at < ?")
+		args = append(args, q.To.UTC())
+	}
+	if q.Status != nil {
+		where = append(where, "status = ?")
+		args = append(args, boolToInt(*q.Status))
+	}
+	if q.After != nil {
+		// Keyset pagination on (checked_at, id) so pages stay stable while
+		// new checks are being written.
+		after := q.After.CheckedAt.UTC()
+		where = append(where, `(checked_at < ? OR (checked_at = ? AND id < ?))`)
+		args = append(args, after, after, q.After.ID)
+	}
+	// Fetch one extra row to know whether another page exists.
+	args = append(args, limit+1)
+
+	rows, err := db.Query(`SELECT id, target_id, target, type, status, duration, checked_at, message FROM checks
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY checked_at DESC, id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var res []CheckRecord
+	for rows.Next() {
+		var r CheckRecord
+		var status int
+		var duration int64
+		var checkedAtStr string
+		if err := rows.Scan(&r.ID, &r.TargetID, &r.Target, &r.Type, &status, &duration, &checkedAtStr, &r.Message); err != nil {
+			return nil, nil, err
+		}
+		r.Status = status == 1
+		r.Duration = time.Duration(duration) * time.Millisecond
+		r.CheckedAt = parseCheckedAt(checkedAtStr)
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *CheckCursor
+	if len(res) > limit {
+		res = res[:limit]
+		last := res[limit-1]
+		next = &CheckCursor{CheckedAt: last.CheckedAt, ID: last.ID}
+	}
+	return res, next, nil
+}
+
+// parseCheckedAt parses a checked_at value as returned by the driver.
+func parseCheckedAt(s string) time.Time {
+	// Parse the timestamp with microseconds and timezone
+	if parsedTime, err := time.Parse("2006-01-02 15:04:05.999999-07:00", s); err == nil {
+		return parsedTime
+	} else if parsedTime, err := time.Parse(time.RFC3339, s); err == nil {
+		return parsedTime
+	}
+	// Fallback to current time if parsing fails
+	return time.Now()
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"uptime/probes"
+)
+
+func TestQueryChecksCursorSurvivesDeletedRow(t *testing.T) {
+	if err := OpenMemory(); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	id, err := AddTarget("api", "https://api.example", "http", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Checks written from different zones must still sort by instant.
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	berlin := time.FixedZone("CET", 3600)
+	for i := 0; i < 5; i++ {
+		at := t0.Add(time.Duration(i) * time.Minute)
+		if i%2 == 1 {
+			at = at.In(berlin)
+		}
+		if err := SaveCheck(probes.Result{Target: "https://api.example", Type: "http", CheckedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	page, next, err := QueryChecks(CheckQuery{TargetID: id, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || next == nil || !page[0].CheckedAt.Equal(t0.Add(4*time.Minute)) {
+		t.Fatalf("first page = %+v, next = %v", page, next)
+	}
+	if _, err := db.Exec("DELETE FROM checks WHERE id = ?", next.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	page, _, err = QueryChecks(CheckQuery{TargetID: id, Limit: 2, After: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || !page[0].CheckedAt.Equal(t0.Add(2*time.Minute)) {
+		t.Fatalf("page after a deleted cursor row = %+v, want the checks at 12:02 and 12:01", page)
+	}
+
+	page, _, err = QueryChecks(CheckQuery{TargetID: id, From: t0.Add(time.Minute).In(berlin), To: t0.Add(3 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 {
+		t.Fatalf("%d checks in [12:01, 12:03) given in mixed zones, want 2", len(page))
+	}
+}
@@ -2,39 +2,108 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"uptime/probes"
+	"uptime/secrets"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var db *sql.DB
 
+// ErrNotFound is returned when a record does not exist.
+var ErrNotFound = errors.New("not found")
+
 // MonitorTarget combines probe with metadata
 type MonitorTarget struct {
+	ID         int
 	Probe      probes.Target
 	Name       string
 	URL        string
+	Type       string
 	Subscribed bool
+	// Paused targets are skipped by the monitor.
+	Paused bool
 }
 
+// SchemaVersion is stored in PRAGMA user_version by createSchema. Bump it
+// whenever the schema changes so restores can reject backups from a newer
+// version.
+const SchemaVersion = 14
+
 func Init() error {
-	var err error
+	return Open(Path())
+}
+
+// Path returns the database file configured via DATABASE_PATH.
+func Path() string {
 	dbPath := os.Getenv("DATABASE_PATH")
 	if dbPath == "" {
 		dbPath = "persistent/monitor.db"
 	}
-	db, err = sql.Open("sqlite3", dbPath)
+	return dbPath
+}
+
+// Open opens the SQLite database at path and creates the schema. The special
+// path ":memory:" gives a private in-memory database, which is what tests use.
+func Open(path string) error {
+	stopWriter()
+
+	dsn := path
+	if path != ":memory:" {
+		// WAL lets API reads run alongside the check writer, and busy_timeout
+		// makes concurrent writers wait instead of failing with
+		// "database is locked".
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL"
+	}
+	conn, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return err
 	}
-	return createSchema()
+	if path == ":memory:" {
+		// Every connection to :memory: is a separate database, so pin the
+		// pool to a single connection to keep all queries on the same one.
+		conn.SetMaxOpenConns(1)
+	}
+	db = conn
+	if err := createSchema(); err != nil {
+		return err
+	}
+	startWriter(db)
+	return nil
+}
+
+// OpenMemory replaces the current database with a fresh in-memory one.
+func OpenMemory() error {
+	return Open(":memory:")
+}
+
+// Close flushes pending checks and closes the current database. It returns
+// an error if the last checks could not be stored.
+func Close() error {
+	err := stopWriter()
+	if db == nil {
+		return err
+	}
+	return errors.Join(err, db.Close())
 }
 
 func createSchema() error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
 	_, err := db.Exec(`
         CREATE TABLE IF NOT EXISTS checks (
         id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -59,26 +128,158 @@ func createSchema() error {
                 frequency INTEGER,
                 timeframe INTEGER
         );
+        CREATE TABLE IF NOT EXISTS meta (
+                key TEXT PRIMARY KEY,
+                value TEXT
+        );
         `)
 	if err != nil {
 		return err
 	}
 
 	// Attempt to add subscribed column if missing
-	_, err = db.Exec(`ALTER TABLE targets ADD COLUMN subscribed INTEGER DEFAULT 0`)
-	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+	if _, err := addColumn("targets", "subscribed INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// Checks used to reference targets only by URL; link existing rows to
+	// their target the first time the column is added.
+	added, err := addColumn("checks", "target_id INTEGER")
+	if err != nil {
+		return err
+	}
+	if added {
+		if _, err := db.Exec(`UPDATE checks SET target_id = (SELECT MIN(id) FROM targets WHERE targets.url = checks.target)`); err != nil {
+			return err
+		}
+	}
+
+	// Targets declared in the config file are read-only in the API.
+	if _, err := addColumn("targets", "managed INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	// The Telegram notifier set by an import; the config file overrides it.
+	if _, err := addColumn("settings", "telegram_bot_token TEXT"); err != nil {
+		return err
+	}
+	if _, err := addColumn("settings", "telegram_chat_id TEXT"); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_checks_target_id_checked_at ON checks(target_id, checked_at);
+        CREATE INDEX IF NOT EXISTS idx_checks_target_checked_at ON checks(target, checked_at);
+        CREATE INDEX IF NOT EXISTS idx_checks_checked_at ON checks(checked_at);
+        `)
+	if err != nil {
+		return err
+	}
+
+	if err := createIncidentSchema(); err != nil {
+		return err
+	}
+	if err := createStateSchema(); err != nil {
+		return err
+	}
+	if err := createSLOSchema(); err != nil {
+		return err
+	}
+	if err := createReportSchema(); err != nil {
+		return err
+	}
+	if err := createUserSchema(); err != nil {
+		return err
+	}
+	if err := createTokenSchema(); err != nil {
+		return err
+	}
+	if err := createAuditSchema(); err != nil {
+		return err
+	}
+	if err := createPauseSchema(); err != nil {
 		return err
 	}
 
+	if version < 14 {
+		for table, columns := range map[string][]string{
+			"checks":        {"checked_at"},
+			"incidents":     {"started_at", "ended_at"},
+			"reports":       {"last_run_at"},
+			"users":         {"created_at"},
+			"sessions":      {"expires_at"},
+			"api_tokens":    {"created_at", "expires_at", "last_used_at"},
+			"audit_log":     {"at"},
+			"target_state":  {"since", "last_checked_at", "last_notified_at"},
+			"target_pauses": {"started_at", "ended_at", "resume_at"},
+		} {
+			if err := migrateToUTC(table, columns...); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Insert default settings if not exist
 	_, err = db.Exec(`INSERT INTO settings(id, frequency, timeframe) VALUES(1, 60, 24) ON CONFLICT(id) DO NOTHING`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SchemaVersion))
 	return err
 }
 
+// migrateToUTC rewrites the given DATETIME columns of table to UTC. Times
+// are stored in UTC because SQLite compares them as text, which only orders
+// them correctly if they share an offset; before schema version 14 some
+// were stored in local time or with the offset a client sent.
+func migrateToUTC(table string, columns ...string) error {
+	for _, col := range columns {
+		utc := `replace(strftime('%Y-%m-%d %H:%M:%f+00:00', ` + col + `), '.000+', '+')`
+		_, err := db.Exec(`UPDATE ` + table + ` SET ` + col + ` = ` + utc +
+			` WHERE ` + col + ` NOT LIKE '%+00:00' AND strftime('%s', ` + col + `) IS NOT NULL`)
+		if err != nil {
+			return fmt.Errorf("migrate %s.%s to UTC: %w", table, col, err)
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to an existing table, reporting whether it was
+// missing.
+func addColumn(table, definition string) (bool, error) {
+	_, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + definition)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// SaveCheck queues a check result for the batched writer. The row becomes
+// visible to queries within about a second, or immediately after Flush. The
+// check is linked to the target whose URL matches res.Target.
 func SaveCheck(res probes.Result) error {
-	_, err := db.Exec(`INSERT INTO checks (target, type, status, duration, checked_at, message)
-        VALUES (?, ?, ?, ?, ?, ?)`, res.Target, res.Type, boolToInt(res.Status), res.Duration.Milliseconds(), res.CheckedAt, res.Message)
-	return err
+	return SaveTargetCheck(0, res, nil)
+}
+
+// SaveTargetCheck is SaveCheck for a known target ID. If state is not nil,
+// the target's persisted state is updated in the same batch.
+func SaveTargetCheck(targetID int, res probes.Result, state *TargetState) error {
+	if writer == nil {
+		return errWriterClosed
+	}
+	return writer.enqueue(checkRow{TargetID: targetID, Result: res, State: state})
+}
+
+// Flush blocks until every check saved so far has been committed.
+func Flush() error {
+	if writer == nil {
+		return nil
+	}
+	return writer.sync()
 }
 
 func boolToInt(b bool) int {
@@ -89,7 +290,25 @@ func boolToInt(b bool) int {
 }
 
 func GetTargets() ([]MonitorTarget, error) {
-	rows, err := db.Query(`SELECT id, name, url, type, username, password, subscribed FROM targets`)
+	return queryMonitorTargets("")
+}
+
+// GetMonitorTarget returns one target with its probe, or ErrNotFound.
+func GetMonitorTarget(id int) (*MonitorTarget, error) {
+	targets, err := queryMonitorTargets("WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, ErrNotFound
+	}
+	return &targets[0], nil
+}
+
+func queryMonitorTargets(where string, args ...any) ([]MonitorTarget, error) {
+	rows, err := db.Query(`SELECT id, name, url, type, username, password, subscribed,
+        EXISTS(SELECT 1 FROM target_pauses WHERE target_id = targets.id AND ended_at IS NULL)
+        FROM targets `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -100,80 +319,87 @@ func GetTargets() ([]MonitorTarget, error) {
 		var id int
 		var name, url, typ string
 		var username, password sql.NullString // Use sql.NullString for nullable columns
-		var subscribed int
-		if err := rows.Scan(&id, &name, &url, &typ, &username, &password, &subscribed); err != nil {
+		var subscribed, paused int
+		if err := rows.Scan(&id, &name, &url, &typ, &username, &password, &subscribed, &paused); err != nil {
 			return nil, err
 		}
-		var probe probes.Target
-		switch typ {
-		case "http":
-			probe = probes.HTTP{URL: url}
-		case "postgres":
-			probe = probes.Postgres{Addr: url, User: username.String, Pass: password.String, DB: "postgres"}
-		case "redis":
-			probe = probes.Redis{Addr: url, User: username.String, Pass: password.String}
-		}
-		targets = append(targets, MonitorTarget{Probe: probe, Name: name, URL: url, Subscribed: subscribed == 1})
-	}
-
-	if len(targets) == 0 {
-		return insertDefaultTargets()
+		probe := BuildProbe(typ, url, username.String, password.String)
+		targets = append(targets, MonitorTarget{ID: id, Probe: probe, Name: name, URL: url, Type: typ,
+			Subscribed: subscribed == 1, Paused: paused == 1})
 	}
 
 	return targets, nil
 }
 
-func insertDefaultTargets() ([]MonitorTarget, error) {
-	defaultTargets := []struct {
-		Name string
-		URL  string
-		Type string
-	}{
-		{"Google", "https://www.google.com", "http"},
-		{"GitHub", "https://github.com", "http"},
-		// The DSNs are now simplified, credentials will be handled separately
-		{"Postgres", "localhost:5432", "postgres"},
-		{"Redis", "localhost:6379", "redis"},
-	}
-
-	tx, err := db.Begin()
+// BuildProbe creates the probe for a target row. Username and password may be
+// secret references (see package secrets); they are resolved here, on every
+// call, so rotated secrets are picked up on the next monitor loop (Vault
+// values once their cache entry expires). Targets of an unknown type, such as
+// one whose probe is no longer registered, get a probe that always fails.
+func BuildProbe(typ, url, username, password string) probes.Target {
+	user, err := secrets.Resolve(username)
 	if err != nil {
-		return nil, err
+		return probes.Failed{Addr: url, Kind: typ, Err: err}
 	}
-	// Add username and password to the insert statement
-	stmt, err := tx.Prepare("INSERT INTO targets(name, url, type, username, password, subscribed) VALUES(?, ?, ?, ?, ?, 0)")
+	pass, err := secrets.Resolve(password)
 	if err != nil {
-		return nil, err
+		return probes.Failed{Addr: url, Kind: typ, Err: err}
+	}
+	switch typ {
+	case "http":
+		return probes.HTTP{URL: url}
+	case "postgres":
+		return probes.Postgres{Addr: url, User: user, Pass: pass, DB: "postgres"}
+	case "redis":
+		return probes.Redis{Addr: url, User: user, Pass: pass}
+	}
+	probeMu.RLock()
+	build, ok := probeBuilders[typ]
+	probeMu.RUnlock()
+	if ok {
+		return build(url, user, pass)
 	}
-	defer stmt.Close()
+	return probes.Failed{Addr: url, Kind: typ, Err: fmt.Errorf("unknown target type %q", typ)}
+}
 
-	var targets []MonitorTarget
-	for _, t := range defaultTargets {
-		var user, pass string
-		// Add dummy credentials for local DBs for the defaults
-		if t.Type == "postgres" {
-			user = "user"
-			pass = "pass"
-		}
-		// Executing with nil for username/password for http targets
-		if _, err := stmt.Exec(t.Name, t.URL, t.Type, user, pass); err != nil {
-			tx.Rollback()
-			return nil, err
-		}
-		var probe probes.Target
-		switch t.Type {
-		case "http":
-			probe = probes.HTTP{URL: t.URL}
-		case "postgres":
-			probe = probes.Postgres{Addr: t.URL, User: user, Pass: pass, DB: "postgres"}
-		case "redis":
-			probe = probes.Redis{Addr: t.URL, User: user, Pass: pass}
-		}
-		targets = append(targets, MonitorTarget{Probe: probe, Name: t.Name, URL: t.URL, Subscribed: false})
+// IsProbeType reports whether BuildProbe knows the target type typ.
+func IsProbeType(typ string) bool {
+	switch typ {
+	case "http", "postgres", "redis":
+		return true
+	}
+	probeMu.RLock()
+	defer probeMu.RUnlock()
+	_, ok := probeBuilders[typ]
+	return ok
+}
+
+// ProbeTypes returns the known target types, sorted.
+func ProbeTypes() []string {
+	types := []string{"http", "postgres", "redis"}
+	probeMu.RLock()
+	for typ := range probeBuilders {
+		types = append(types, typ)
 	}
-	tx.Commit()
+	probeMu.RUnlock()
+	sort.Strings(types)
+	return types
+}
 
-	return targets, nil
+// ProbeBuilder creates a probe from a target's URL and resolved credentials.
+type ProbeBuilder func(url, username, password string) probes.Target
+
+var (
+	probeMu       sync.RWMutex
+	probeBuilders = map[string]ProbeBuilder{}
+)
+
+// RegisterProbe makes BuildProbe use fn for targets of the given type. It is
+// how tests plug in stub probes; the built-in types cannot be overridden.
+func RegisterProbe(typ string, fn ProbeBuilder) {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	probeBuilders[typ] = fn
 }
 
 type TargetInfo struct {
@@ -183,12 +409,46 @@ type TargetInfo struct {
 	Type       string `json:"type"`
 	Username   string `json:"username,omitempty"`
 	Subscribed bool   `json:"subscribed"`
-	// Password is intentionally omitted for security
+	// Password is intentionally omitted for security. When the stored
+	// password is a secret reference, the reference itself is returned
+	// (never the resolved value) so the UI can show where it comes from.
+	PasswordRef string `json:"passwordRef,omitempty"`
+	// Managed targets come from the config file and cannot be edited
+	// through the API.
+	Managed bool `json:"managed"`
+	// State is nil until the target has been checked.
+	State *TargetState `json:"state,omitempty"`
+	// Paused is set while the target is paused.
+	Paused *Pause `json:"paused,omitempty"`
 }
 
 func GetTargetInfos() ([]TargetInfo, error) {
+	return queryTargetInfos("")
+}
+
+// GetTargetInfo returns one target, or ErrNotFound.
+func GetTargetInfo(id int) (*TargetInfo, error) {
+	targets, err := queryTargetInfos("WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, ErrNotFound
+	}
+	return &targets[0], nil
+}
+
+func queryTargetInfos(where string, args ...any) ([]TargetInfo, error) {
 	// Select the new columns but don't expose password
-	rows, err := db.Query(`SELECT id, name, url, type, username, subscribed FROM targets ORDER BY id`)
+	states, err := GetTargetStates()
+	if err != nil {
+		return nil, err
+	}
+	pauses, err := GetPauses()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT id, name, url, type, username, password, subscribed, managed FROM targets `+where+` ORDER BY id`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -197,34 +457,78 @@ func GetTargetInfos() ([]TargetInfo, error) {
 	var targets []TargetInfo
 	for rows.Next() {
 		var t TargetInfo
-		var subscribed int
-		if err := rows.Scan(&t.ID, &t.Name, &t.URL, &t.Type, &t.Username, &subscribed); err != nil {
+		var password sql.NullString
+		var subscribed, managed int
+		if err := rows.Scan(&t.ID, &t.Name, &t.URL, &t.Type, &t.Username, &password, &subscribed, &managed); err != nil {
 			return nil, err
 		}
 		t.Subscribed = subscribed == 1
+		t.Managed = managed == 1
+		if secrets.IsRef(password.String) {
+			t.PasswordRef = password.String
+		}
+		if st, ok := states[t.ID]; ok {
+			t.State = &st
+		}
+		if p, ok := pauses[t.ID]; ok {
+			t.Paused = &p
+		}
 		targets = append(targets, t)
 	}
-	return targets, nil
+	return targets, rows.Err()
 }
 
-func AddTarget(name, url, typ, username, password string) error {
-	_, err := db.Exec("INSERT INTO targets(name, url, type, username, password, subscribed) VALUES(?, ?, ?, ?, ?, 0)", name, url, typ, username, password)
-	return err
+// AddTarget stores a new target and returns its ID.
+func AddTarget(name, url, typ, username, password string) (int, error) {
+	res, err := db.Exec("INSERT INTO targets(name, url, type, username, password, subscribed) VALUES(?, ?, ?, ?, ?, 0)", name, url, typ, username, password)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
 }
 
+// UpdateTarget changes a target, keeping its password if password is empty.
+// It returns ErrNotFound if there is no such target.
 func UpdateTarget(id int, name, url, typ, username, password string) error {
 	// Only update password if a new one is provided.
+	query := "UPDATE targets SET name = ?, url = ?, type = ?, username = ? WHERE id = ?"
+	args := []any{name, url, typ, username, id}
 	if password != "" {
-		_, err := db.Exec("UPDATE targets SET name = ?, url = ?, type = ?, username = ?, password = ? WHERE id = ?", name, url, typ, username, password, id)
+		query = "UPDATE targets SET name = ?, url = ?, type = ?, username = ?, password = ? WHERE id = ?"
+		args = []any{name, url, typ, username, password, id}
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
 		return err
 	}
-	_, err := db.Exec("UPDATE targets SET name = ?, url = ?, type = ?, username = ? WHERE id = ?", name, url, typ, username, id)
-	return err
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-func DeleteTarget(id int) error {
-	_, err := db.Exec("DELETE FROM targets WHERE id = ?", id)
-	return err
+// TargetExists returns ErrNotFound unless target id exists.
+func TargetExists(id int) error {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM targets WHERE id = ?", id).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteTarget deletes a target, returning ErrNotFound if there is none. Its
+// open incident ends at at.
+func DeleteTarget(id int, at time.Time) error {
+	if err := TargetExists(id); err != nil {
+		return err
+	}
+	return deleteTarget(db, id, at)
 }
 
 func SubscribeTarget(id int) error {
@@ -238,13 +542,27 @@ func UnsubscribeTarget(id int) error {
 }
 
 func ClearChecks(target string) error {
+	// Commit queued checks first so they cannot reappear after the delete.
+	if err := Flush(); err != nil {
+		return err
+	}
 	_, err := db.Exec("DELETE FROM checks WHERE target = ?", target)
 	return err
 }
 
+// CountChecks returns the number of stored checks of a target URL.
+func CountChecks(target string) (int, error) {
+	if err := Flush(); err != nil {
+		return 0, err
+	}
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM checks WHERE target = ?", target).Scan(&n)
+	return n, err
+}
+
 // LastChecks returns all checks within timeframe hours
 func LastChecks(hours int) ([]probes.Result, error) {
-	start := time.Now().Add(-time.Duration(hours) * time.Hour)
+	start := time.Now().UTC().Add(-time.Duration(hours) * time.Hour)
 	rows, err := db.Query(`SELECT target, type, status, duration, checked_at, message FROM checks WHERE checked_at >= ? ORDER BY checked_at DESC`, start)
 	if err != nil {
 		return nil, err
@@ -261,15 +579,7 @@ func LastChecks(hours int) ([]probes.Result, error) {
 		}
 		r.Status = status == 1
 		r.Duration = time.Duration(duration) * time.Millisecond
-		// Parse the timestamp with microseconds and timezone
-		if parsedTime, err := time.Parse("2006-01-02 15:04:05.999999-07:00", checkedAtStr); err == nil {
-			r.CheckedAt = parsedTime
-		} else if parsedTime, err := time.Parse(time.RFC3339, checkedAtStr); err == nil {
-			r.CheckedAt = parsedTime
-		} else {
-			// Fallback to current time if parsing fails
-			r.CheckedAt = time.Now()
-		}
+		r.CheckedAt = parseCheckedAt(checkedAtStr)
 		res = append(res, r)
 	}
 	return res, nil
@@ -304,3 +614,23 @@ func UpdateSettings(freq, timeframe int) error {
 	}
 	return err
 }
+
+// TelegramSettings is the stored Telegram notifier. Both values may be
+// secret references.
+type TelegramSettings struct {
+	BotToken string
+	ChatID   string
+}
+
+// GetTelegramSettings returns the stored Telegram notifier, or nil if none
+// is stored.
+func GetTelegramSettings() (*TelegramSettings, error) {
+	var token, chatID sql.NullString
+	if err := db.QueryRow("SELECT telegram_bot_token, telegram_chat_id FROM settings WHERE id=1").Scan(&token, &chatID); err != nil {
+		return nil, err
+	}
+	if token.String == "" && chatID.String == "" {
+		return nil, nil
+	}
+	return &TelegramSettings{BotToken: token.String, ChatID: chatID.String}, nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenMigratesTimesToUTC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "uptime.db")
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	// Rows as written before schema version 14, with the server's offset.
+	if _, err := db.Exec(`INSERT INTO incidents (target_id, target_name, started_at, ended_at, last_error, first_error, failed_checks)
+        VALUES (1, 'api', '2024-01-01 13:00:00.5+01:00', '2024-01-01 14:30:00+01:00', '', '', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`PRAGMA user_version = 13`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	var started, ended string
+	if err := db.QueryRow(`SELECT CAST(started_at AS TEXT), CAST(ended_at AS TEXT) FROM incidents`).Scan(&started, &ended); err != nil {
+		t.Fatal(err)
+	}
+	if started != "2024-01-01 12:00:00.500+00:00" || ended != "2024-01-01 13:30:00+00:00" {
+		t.Fatalf("migrated times = %q, %q, want them in UTC", started, ended)
+	}
+	incs, err := ListIncidents(IncidentQuery{From: time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incs) != 1 {
+		t.Fatalf("%d incidents ending after 13:00 UTC, want 1", len(incs))
+	}
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// TargetRecord is a target including its stored (unresolved) password, for
+// export only. Never serve it from regular API endpoints.
+type TargetRecord struct {
+	TargetInfo
+	Password string
+}
+
+func GetTargetRecords() ([]TargetRecord, error) {
+	rows, err := db.Query(`SELECT id, name, url, type, username, password, subscribed, managed FROM targets ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []TargetRecord
+	for rows.Next() {
+		var t TargetRecord
+		var username, password sql.NullString
+		var subscribed, managed int
+		if err := rows.Scan(&t.ID, &t.Name, &t.URL, &t.Type, &username, &password, &subscribed, &managed); err != nil {
+			return nil, err
+		}
+		t.Username = username.String
+		t.Password = password.String
+		t.Subscribed = subscribed == 1
+		t.Managed = managed == 1
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// StreamChecks calls fn for every check of targetID (all targets when 0)
+// between from and to, oldest first, without loading them all into memory.
+// Zero times mean no bound.
+func StreamChecks(targetID int, from, to time.Time, fn func(CheckRecord) error) error {
+	if err := Flush(); err != nil {
+		return err
+	}
+	var where []string
+	var args []any
+	if targetID != 0 {
+		where = append(where, "target_id = ?")
+		args = append(args, targetID)
+	}
+	if !from.IsZero() {
+		where = append(where, "checked_at >= ?")
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		where = append(where, "checked_at < ?")
+		args = append(args, to.UTC())
+	}
+	query := `SELECT id, COALESCE(target_id, 0), target, type, status, duration, checked_at, message FROM checks`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := db.Query(query+" ORDER BY checked_at, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r CheckRecord
+		var status int
+		var duration int64
+		var checkedAtStr string
+		if err := rows.Scan(&r.ID, &r.TargetID, &r.Target, &r.Type, &status, &duration, &checkedAtStr, &r.Message); err != nil {
+			return err
+		}
+		r.Status = status == 1
+		r.Duration = time.Duration(duration) * time.Millisecond
+		r.CheckedAt = parseCheckedAt(checkedAtStr)
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// TargetSpec is the user-editable part of a target.
+type TargetSpec struct {
+	Name       string
+	URL        string
+	Type       string
+	Username   string
+	Password   string
+	Subscribed bool
+}
+
+// ImportedCheck is a historical check belonging to the target named
+// TargetName.
+type ImportedCheck struct {
+	TargetName string
+	CheckRecord
+}
+
+// ImportPlan is a set of changes applied atomically by ApplyImport.
+type ImportPlan struct {
+	Create []TargetSpec
+	// Update maps existing target IDs to their new definition. An empty
+	// password keeps the stored one.
+	Update   map[int]TargetSpec
+	Delete   []int
+	Settings *Settings
+	// Telegram replaces the stored Telegram notifier. An empty value keeps
+	// the stored one.
+	Telegram *TelegramSettings
+	// ClearChecks deletes all existing history before Checks are inserted.
+	ClearChecks bool
+	Checks      []ImportedCheck
+}
+
+// ApplyImport applies p in a single transaction, as of at. Imported checks
+// that already exist (same target and timestamp) are skipped.
+func ApplyImport(p ImportPlan, at time.Time) error {
+	if err := Flush(); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range p.Delete {
+		if err := deleteTarget(tx, id, at); err != nil {
+			return err
+		}
+	}
+	for id, t := range p.Update {
+		if t.Password != "" {
+			_, err = tx.Exec("UPDATE targets SET name = ?, url = ?, type = ?, username = ?, password = ?, subscribed = ? WHERE id = ?",
+				t.Name, t.URL, t.Type, t.Username, t.Password, boolToInt(t.Subscribed), id)
+		} else {
+			_, err = tx.Exec("UPDATE targets SET name = ?, url = ?, type = ?, username = ?, subscribed = ? WHERE id = ?",
+				t.Name, t.URL, t.Type, t.Username, boolToInt(t.Subscribed), id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range p.Create {
+		if _, err := tx.Exec("INSERT INTO targets(name, url, type, username, password, subscribed) VALUES(?, ?, ?, ?, ?, ?)",
+			t.Name, t.URL, t.Type, t.Username, t.Password, boolToInt(t.Subscribed)); err != nil {
+			return err
+		}
+	}
+	if p.Settings != nil {
+		if _, err := tx.Exec("UPDATE settings SET frequency = ?, timeframe = ? WHERE id = 1", p.Settings.Frequency, p.Settings.TimeframeHours); err != nil {
+			return err
+		}
+	}
+	if t := p.Telegram; t != nil {
+		if _, err := tx.Exec(`UPDATE settings SET telegram_bot_token = COALESCE(NULLIF(?, ''), telegram_bot_token),
+                telegram_chat_id = COALESCE(NULLIF(?, ''), telegram_chat_id) WHERE id = 1`, t.BotToken, t.ChatID); err != nil {
+			return err
+		}
+	}
+
+	if p.ClearChecks {
+		if _, err := tx.Exec("DELETE FROM checks"); err != nil {
+			return err
+		}
+	}
+	if len(p.Checks) > 0 {
+		ids := map[string]int{}
+		rows, err := tx.Query("SELECT id, name FROM targets")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			if _, ok := ids[name]; !ok {
+				ids[name] = id
+			}
+		}
+		rows.Close()
+
+		stmt, err := tx.Prepare(`INSERT INTO checks (target_id, target, type, status, duration, checked_at, message)
+                SELECT ?, ?, ?, ?, ?, ?, ?
+                WHERE NOT EXISTS (SELECT 1 FROM checks WHERE target_id = ? AND checked_at = ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range p.Checks {
+			id, ok := ids[c.TargetName]
+			if !ok {
+				continue
+			}
+			if _, err := stmt.Exec(id, c.Target, c.Type, boolToInt(c.Status), c.Duration.Milliseconds(), c.CheckedAt.UTC(), c.Message, id, c.CheckedAt.UTC()); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
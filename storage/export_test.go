@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"uptime/probes"
+)
+
+func TestApplyImportSkipsCheckAtSameInstant(t *testing.T) {
+	if err := OpenMemory(); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if _, err := AddTarget("api", "https://api.example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	check := func(at time.Time) ImportedCheck {
+		return ImportedCheck{TargetName: "api", CheckRecord: CheckRecord{Result: probes.Result{Target: "https://api.example", Type: "http", CheckedAt: at}}}
+	}
+	if err := ApplyImport(ImportPlan{Checks: []ImportedCheck{check(at)}}, at); err != nil {
+		t.Fatal(err)
+	}
+	// The same check from a document written in another zone.
+	if err := ApplyImport(ImportPlan{Checks: []ImportedCheck{check(at.In(time.FixedZone("CET", 3600)))}}, at); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM checks`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("%d checks after importing one check twice, want 1", n)
+	}
+}
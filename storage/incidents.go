@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Incident is one outage of a target, from its first failed check until the
+// next successful one. EndedAt is nil while the incident is open.
+type Incident struct {
+	ID           int64      `json:"id"`
+	TargetID     int        `json:"targetId"`
+	TargetName   string     `json:"targetName"`
+	StartedAt    time.Time  `json:"startedAt"`
+	EndedAt      *time.Time `json:"endedAt,omitempty"`
+	FirstError   string     `json:"firstError"`
+	LastError    string     `json:"lastError"`
+	FailedChecks int        `json:"failedChecks"`
+	Notes        string     `json:"notes"`
+	RootCause    string     `json:"rootCause"`
+}
+
+func createIncidentSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS incidents (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                target_id INTEGER,
+                target_name TEXT,
+                started_at DATETIME,
+                ended_at DATETIME,
+                first_error TEXT,
+                last_error TEXT,
+                failed_checks INTEGER DEFAULT 0,
+                notes TEXT DEFAULT '',
+                root_cause TEXT DEFAULT ''
+        );
+        CREATE INDEX IF NOT EXISTS idx_incidents_target_started ON incidents(target_id, started_at);
+        CREATE INDEX IF NOT EXISTS idx_incidents_open ON incidents(target_id) WHERE ended_at IS NULL;
+        `)
+	return err
+}
+
+// OpenIncident starts an incident for a target unless one is already open,
+// in which case the failure is added to it. It reports whether a new
+// incident was opened.
+func OpenIncident(targetID int, targetName string, at time.Time, message string) (bool, error) {
+	res, err := db.Exec(`UPDATE incidents SET last_error = ?, failed_checks = failed_checks + 1
+        WHERE target_id = ? AND ended_at IS NULL`, message, targetID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return false, err
+	}
+	_, err = db.Exec(`INSERT INTO incidents(target_id, target_name, started_at, first_error, last_error, failed_checks)
+        VALUES(?, ?, ?, ?, ?, 1)`, targetID, targetName, at.UTC(), message, message)
+	return err == nil, err
+}
+
+// CloseIncident ends the open incident of a target, if any, reporting
+// whether one was closed.
+func CloseIncident(targetID int, at time.Time) (bool, error) {
+	res, err := db.Exec(`UPDATE incidents SET ended_at = ? WHERE target_id = ? AND ended_at IS NULL`, at.UTC(), targetID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// deleteTarget deletes a target with its state and pauses and closes its
+// open incident at at, as it could otherwise never recover.
+func deleteTarget(ex execer, id int, at time.Time) error {
+	if _, err := ex.Exec("DELETE FROM targets WHERE id = ?", id); err != nil {
+		return err
+	}
+	if _, err := ex.Exec("DELETE FROM target_state WHERE target_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := ex.Exec("DELETE FROM target_pauses WHERE target_id = ?", id); err != nil {
+		return err
+	}
+	_, err := ex.Exec("UPDATE incidents SET ended_at = ? WHERE target_id = ? AND ended_at IS NULL", at.UTC(), id)
+	return err
+}
+
+// OpenIncidentTargets returns the IDs of targets that have an open incident.
+func OpenIncidentTargets() (map[int]bool, error) {
+	rows, err := db.Query(`SELECT target_id FROM incidents WHERE ended_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	open := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		open[id] = true
+	}
+	return open, rows.Err()
+}
+
+// IncidentQuery filters ListIncidents. Zero values mean no filter; From/To
+// select incidents overlapping the window.
+type IncidentQuery struct {
+	TargetID int
+	From     time.Time
+	To       time.Time
+	OpenOnly bool
+	Limit    int
+}
+
+// ListIncidents returns incidents matching q, newest first.
+func ListIncidents(q IncidentQuery) ([]Incident, error) {
+	var where []string
+	var args []any
+	if q.TargetID != 0 {
+		where = append(where, "target_id = ?")
+		args = append(args, q.TargetID)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "(ended_at IS NULL OR ended_at >= ?)")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "started_at < ?")
+		args = append(args, q.To.UTC())
+	}
+	if q.OpenOnly {
+		where = append(where, "ended_at IS NULL")
+	}
+	query := `SELECT ` + incidentColumns + ` FROM incidents`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY started_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	incidents := []Incident{}
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, *inc)
+	}
+	return incidents, rows.Err()
+}
+
+func GetIncident(id int64) (*Incident, error) {
+	inc, err := scanIncident(db.QueryRow(`SELECT `+incidentColumns+` FROM incidents WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return inc, err
+}
+
+// AnnotateIncident sets the free-text notes and root cause of an incident.
+func AnnotateIncident(id int64, notes, rootCause string) error {
+	res, err := db.Exec(`UPDATE incidents SET notes = ?, root_cause = ? WHERE id = ?`, notes, rootCause, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const incidentColumns = `id, target_id, target_name, started_at, ended_at, first_error, last_error, failed_checks, notes, root_cause`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIncident(row rowScanner) (*Incident, error) {
+	var inc Incident
+	var ended sql.NullTime
+	var firstError, lastError, notes, rootCause sql.NullString
+	if err := row.Scan(&inc.ID, &inc.TargetID, &inc.TargetName, &inc.StartedAt, &ended,
+		&firstError, &lastError, &inc.FailedChecks, &notes, &rootCause); err != nil {
+		return nil, err
+	}
+	if ended.Valid {
+		inc.EndedAt = &ended.Time
+	}
+	inc.FirstError = firstError.String
+	inc.LastError = lastError.String
+	inc.Notes = notes.String
+	inc.RootCause = rootCause.String
+	return &inc, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrManaged is returned when a config-managed target is modified through
+// the API.
+var ErrManaged = errors.New("target is managed by the config file")
+
+// ReconcileResult lists the target names changed by ReconcileTargets.
+type ReconcileResult struct {
+	Created []string
+	Updated []string
+	Deleted []string
+}
+
+// ReconcileTargets makes the managed targets match specs in one transaction:
+// missing ones are created, changed ones updated and managed targets no longer
+// declared are deleted. An existing unmanaged target with a declared name is
+// adopted. Targets created through the API are otherwise left alone. Open
+// incidents of deleted targets end at at.
+func ReconcileTargets(specs []TargetSpec, at time.Time) (*ReconcileResult, error) {
+	existing, err := GetTargetRecords()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]TargetRecord, len(existing))
+	for _, t := range existing {
+		if cur, ok := byName[t.Name]; !ok || (t.Managed && !cur.Managed) {
+			byName[t.Name] = t
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res := &ReconcileResult{}
+	declared := make(map[string]bool, len(specs))
+	for _, t := range specs {
+		declared[t.Name] = true
+		cur, ok := byName[t.Name]
+		if !ok {
+			if _, err := tx.Exec("INSERT INTO targets(name, url, type, username, password, subscribed, managed) VALUES(?, ?, ?, ?, ?, ?, 1)",
+				t.Name, t.URL, t.Type, t.Username, t.Password, boolToInt(t.Subscribed)); err != nil {
+				return nil, err
+			}
+			res.Created = append(res.Created, t.Name)
+			continue
+		}
+		if cur.Managed && cur.URL == t.URL && cur.Type == t.Type && cur.Username == t.Username &&
+			cur.Password == t.Password && cur.Subscribed == t.Subscribed {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE targets SET url = ?, type = ?, username = ?, password = ?, subscribed = ?, managed = 1 WHERE id = ?",
+			t.URL, t.Type, t.Username, t.Password, boolToInt(t.Subscribed), cur.ID); err != nil {
+			return nil, err
+		}
+		res.Updated = append(res.Updated, t.Name)
+	}
+	for _, t := range existing {
+		if t.Managed && !declared[t.Name] {
+			if err := deleteTarget(tx, t.ID, at); err != nil {
+				return nil, err
+			}
+			res.Deleted = append(res.Deleted, t.Name)
+		}
+	}
+	return res, tx.Commit()
+}
+
+// IsManaged reports whether the target with the given ID is config-managed.
+func IsManaged(id int) (bool, error) {
+	var managed int
+	err := db.QueryRow("SELECT managed FROM targets WHERE id = ?", id).Scan(&managed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return managed == 1, err
+}
+
+// ReleaseManagedTargets makes every config-managed target editable through
+// the API again, for when the server runs without a config file. It returns
+// the names of the released targets.
+func ReleaseManagedTargets() ([]string, error) {
+	rows, err := db.Query("UPDATE targets SET managed = 0 WHERE managed = 1 RETURNING name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReleaseManagedTargets(t *testing.T) {
+	if err := OpenMemory(); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if _, err := ReconcileTargets([]TargetSpec{{Name: "api", URL: "https://api.example", Type: "http"}}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddTarget("web", "https://web.example", "http", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := ReleaseManagedTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"api"}) {
+		t.Fatalf("released %q, want [api]", names)
+	}
+	targets, err := GetTargetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range targets {
+		if tr.Managed {
+			t.Fatalf("target %s is still managed", tr.Name)
+		}
+	}
+}
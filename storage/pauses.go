@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Pause describes a paused target: it is not checked from Since until it is
+// resumed, by hand or, if ResumeAt is set, by the monitor at that time.
+type Pause struct {
+	Since    time.Time  `json:"since"`
+	ResumeAt *time.Time `json:"resumeAt,omitempty"`
+}
+
+// PausePeriod is a span of time in which a target was paused.
+type PausePeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+func createPauseSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS target_pauses (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                target_id INTEGER,
+                started_at DATETIME,
+                ended_at DATETIME,
+                resume_at DATETIME
+        );
+        CREATE INDEX IF NOT EXISTS idx_target_pauses_target_started ON target_pauses(target_id, started_at);
+        CREATE INDEX IF NOT EXISTS idx_target_pauses_open ON target_pauses(target_id) WHERE ended_at IS NULL;
+        `)
+	return err
+}
+
+// PauseTarget pauses a target from at on, until resumeAt if that is not nil.
+// Pausing a paused target only changes when it resumes. It returns
+// ErrNotFound if the target does not exist.
+func PauseTarget(id int, at time.Time, resumeAt *time.Time) error {
+	if err := TargetExists(id); err != nil {
+		return err
+	}
+	var resume any
+	if resumeAt != nil {
+		resume = resumeAt.UTC()
+	}
+	res, err := db.Exec(`UPDATE target_pauses SET resume_at = ? WHERE target_id = ? AND ended_at IS NULL`, resume, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO target_pauses(target_id, started_at, resume_at) VALUES(?, ?, ?)`, id, at.UTC(), resume)
+	return err
+}
+
+// ResumeTarget ends the pause of a target, if any, reporting whether it was
+// paused. It returns ErrNotFound if the target does not exist.
+func ResumeTarget(id int, at time.Time) (bool, error) {
+	if err := TargetExists(id); err != nil {
+		return false, err
+	}
+	res, err := db.Exec(`UPDATE target_pauses SET ended_at = ? WHERE target_id = ? AND ended_at IS NULL`, at.UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ResumeDue ends the pauses whose resume time is at or before at, as of
+// their resume time, and returns the IDs of the resumed targets.
+func ResumeDue(at time.Time) ([]int, error) {
+	at = at.UTC()
+	rows, err := db.Query(`SELECT target_id FROM target_pauses
+        WHERE ended_at IS NULL AND resume_at IS NOT NULL AND resume_at <= ?`, at)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	_, err = db.Exec(`UPDATE target_pauses SET ended_at = resume_at
+        WHERE ended_at IS NULL AND resume_at IS NOT NULL AND resume_at <= ?`, at)
+	return ids, err
+}
+
+// GetPauses returns the current pause of every paused target by ID.
+func GetPauses() (map[int]Pause, error) {
+	rows, err := db.Query(`SELECT target_id, started_at, resume_at FROM target_pauses WHERE ended_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pauses := map[int]Pause{}
+	for rows.Next() {
+		var id int
+		var p Pause
+		var resume sql.NullTime
+		if err := rows.Scan(&id, &p.Since, &resume); err != nil {
+			return nil, err
+		}
+		if resume.Valid {
+			p.ResumeAt = &resume.Time
+		}
+		pauses[id] = p
+	}
+	return pauses, rows.Err()
+}
+
+// PausePeriods returns the pauses of a target overlapping [from, to),
+// clipped to the window and oldest first. A pause that has not ended yet
+// ends at to.
+func PausePeriods(targetID int, from, to time.Time) ([]PausePeriod, error) {
+	from, to = from.UTC(), to.UTC()
+	rows, err := db.Query(`SELECT started_at, ended_at FROM target_pauses
+        WHERE target_id = ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)
+        ORDER BY started_at`, targetID, to, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var periods []PausePeriod
+	for rows.Next() {
+		var p PausePeriod
+		var ended sql.NullTime
+		if err := rows.Scan(&p.Start, &ended); err != nil {
+			return nil, err
+		}
+		p.End = to
+		if ended.Valid && ended.Time.Before(to) {
+			p.End = ended.Time
+		}
+		if p.Start.Before(from) {
+			p.Start = from
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
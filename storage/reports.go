@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Report is a scheduled availability report over a group of targets (every
+// target when TargetIDs is empty). Period is "daily", "weekly" or "monthly"
+// and Format, the attachment format, is "csv" or "html". LastRunAt is when
+// the report was last delivered.
+type Report struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Period    string     `json:"period"`
+	Format    string     `json:"format"`
+	TargetIDs []int      `json:"targetIds"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+}
+
+func createReportSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS reports (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name TEXT,
+                period TEXT,
+                format TEXT,
+                last_run_at DATETIME
+        );
+        CREATE TABLE IF NOT EXISTS report_targets (
+                report_id INTEGER,
+                target_id INTEGER,
+                PRIMARY KEY (report_id, target_id)
+        );
+        `)
+	return err
+}
+
+func ListReports() ([]Report, error) {
+	members, err := groupMembers(`SELECT report_id, target_id FROM report_targets ORDER BY report_id, target_id`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT id, name, period, format, last_run_at FROM reports ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := []Report{}
+	for rows.Next() {
+		var r Report
+		var lastRun sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Name, &r.Period, &r.Format, &lastRun); err != nil {
+			return nil, err
+		}
+		if lastRun.Valid {
+			r.LastRunAt = &lastRun.Time
+		}
+		r.TargetIDs = members[r.ID]
+		if r.TargetIDs == nil {
+			r.TargetIDs = []int{}
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+func GetReport(id int64) (*Report, error) {
+	reports, err := ListReports()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reports {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// SaveReport inserts r when its ID is zero and updates it otherwise,
+// returning the ID. LastRunAt is only written on insert; use SetReportRun to
+// change it afterwards.
+func SaveReport(r Report) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id := r.ID
+	if id == 0 {
+		var lastRun any
+		if r.LastRunAt != nil {
+			lastRun = r.LastRunAt.UTC()
+		}
+		res, err := tx.Exec(`INSERT INTO reports(name, period, format, last_run_at) VALUES(?, ?, ?, ?)`,
+			r.Name, r.Period, r.Format, lastRun)
+		if err != nil {
+			return 0, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	} else {
+		res, err := tx.Exec(`UPDATE reports SET name = ?, period = ?, format = ? WHERE id = ?`,
+			r.Name, r.Period, r.Format, id)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return 0, err
+		} else if n == 0 {
+			return 0, ErrNotFound
+		}
+		if _, err := tx.Exec(`DELETE FROM report_targets WHERE report_id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	for _, targetID := range r.TargetIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO report_targets(report_id, target_id) VALUES(?, ?)`, id, targetID); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+func DeleteReport(id int64) error {
+	res, err := db.Exec(`DELETE FROM reports WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	_, err = db.Exec(`DELETE FROM report_targets WHERE report_id = ?`, id)
+	return err
+}
+
+// SetReportRun records when a report was last delivered.
+func SetReportRun(id int64, at time.Time) error {
+	_, err := db.Exec(`UPDATE reports SET last_run_at = ? WHERE id = ?`, at.UTC(), id)
+	return err
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SeriesBucket aggregates the checks of one target in [Start, Start+step).
+// Latency figures are in milliseconds and only cover successful checks; they
+// and Uptime are nil when the bucket has no such checks.
+type SeriesBucket struct {
+	Start    time.Time `json:"start"`
+	Count    int       `json:"count"`
+	Failures int       `json:"failures"`
+	Uptime   *float64  `json:"uptime"`
+	AvgMs    *float64  `json:"avgMs"`
+	P50Ms    *int64    `json:"p50Ms"`
+	P95Ms    *int64    `json:"p95Ms"`
+	P99Ms    *int64    `json:"p99Ms"`
+}
+
+// CheckSeries buckets a target's checks between from and to into intervals of
+// step, computing counts, uptime ratio and nearest-rank latency percentiles in
+// SQL. Every bucket in the range is returned, including empty ones.
+func CheckSeries(targetID int, from, to time.Time, step time.Duration) ([]SeriesBucket, error) {
+	from, to = from.UTC(), to.UTC()
+	stepSec := int64(step / time.Second)
+	if stepSec < 1 {
+		stepSec = 1
+	}
+	origin := from.Unix()
+
+	rows, err := db.Query(`
+        WITH bucketed AS (
+                SELECT (CAST(strftime('%s', checked_at) AS INTEGER) - ?) / ? AS bucket, status, duration
+                FROM checks
+                WHERE target_id = ? AND checked_at >= ? AND checked_at < ?
+        ), ranked AS (
+                SELECT bucket, status, duration,
+                        ROW_NUMBER() OVER (PARTITION BY bucket, status ORDER BY duration) AS rn,
+                        COUNT(*) OVER (PARTITION BY bucket, status) AS n
+                FROM bucketed
+        )
+        SELECT bucket,
+                COUNT(*),
+                SUM(status),
+                AVG(CASE WHEN status = 1 THEN duration END),
+                MIN(CASE WHEN status = 1 AND rn * 100 >= n * 50 THEN duration END),
+                MIN(CASE WHEN status = 1 AND rn * 100 >= n * 95 THEN duration END),
+                MIN(CASE WHEN status = 1 AND rn * 100 >= n * 99 THEN duration END)
+        FROM ranked
+        GROUP BY bucket
+        ORDER BY bucket`, origin, stepSec, targetID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	n := int((to.Unix() - origin + stepSec - 1) / stepSec)
+	if n < 0 {
+		n = 0
+	}
+	buckets := make([]SeriesBucket, n)
+	for i := range buckets {
+		buckets[i].Start = from.Add(time.Duration(int64(i)*stepSec) * time.Second)
+	}
+
+	for rows.Next() {
+		var idx int
+		var count, up int
+		var avg sql.NullFloat64
+		var p50, p95, p99 sql.NullInt64
+		if err := rows.Scan(&idx, &count, &up, &avg, &p50, &p95, &p99); err != nil {
+			return nil, err
+		}
+		if idx < 0 || idx >= n {
+			continue
+		}
+		b := &buckets[idx]
+		b.Count = count
+		b.Failures = count - up
+		if count > 0 {
+			ratio := float64(up) / float64(count)
+			b.Uptime = &ratio
+		}
+		if avg.Valid {
+			b.AvgMs = &avg.Float64
+		}
+		if p50.Valid {
+			b.P50Ms = &p50.Int64
+		}
+		if p95.Valid {
+			b.P95Ms = &p95.Int64
+		}
+		if p99.Valid {
+			b.P99Ms = &p99.Int64
+		}
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// SLO is a service level objective over one or more targets: Objective
+// percent of checks must be good over a rolling window of WindowDays. A check
+// is good when it succeeded and, if LatencyThresholdMs is set, took at most
+// that long. This makes LatencyThresholdMs a per-check latency-threshold SLI
+// ("99% of checks succeed within 500ms"), not a bound on a percentile such as
+// p95. An empty TargetIDs covers every target.
+type SLO struct {
+	ID                 int64   `json:"id"`
+	Name               string  `json:"name"`
+	TargetIDs          []int   `json:"targetIds"`
+	Objective          float64 `json:"objective"`
+	LatencyThresholdMs int     `json:"latencyThresholdMs,omitempty"`
+	WindowDays         int     `json:"windowDays"`
+	// Alerting is set while a burn-rate alert is firing.
+	Alerting bool `json:"alerting"`
+}
+
+func createSLOSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS slos (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name TEXT,
+                objective REAL,
+                latency_threshold_ms INTEGER DEFAULT 0,
+                window_days INTEGER DEFAULT 30,
+                alerting INTEGER DEFAULT 0
+        );
+        CREATE TABLE IF NOT EXISTS slo_targets (
+                slo_id INTEGER,
+                target_id INTEGER,
+                PRIMARY KEY (slo_id, target_id)
+        );
+        `)
+	return err
+}
+
+func ListSLOs() ([]SLO, error) {
+	members, err := groupMembers(`SELECT slo_id, target_id FROM slo_targets ORDER BY slo_id, target_id`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT id, name, objective, latency_threshold_ms, window_days, alerting FROM slos ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	slos := []SLO{}
+	for rows.Next() {
+		var s SLO
+		var alerting int
+		if err := rows.Scan(&s.ID, &s.Name, &s.Objective, &s.LatencyThresholdMs, &s.WindowDays, &alerting); err != nil {
+			return nil, err
+		}
+		s.Alerting = alerting == 1
+		s.TargetIDs = members[s.ID]
+		if s.TargetIDs == nil {
+			s.TargetIDs = []int{}
+		}
+		slos = append(slos, s)
+	}
+	return slos, rows.Err()
+}
+
+func GetSLO(id int64) (*SLO, error) {
+	slos, err := ListSLOs()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range slos {
+		if s.ID == id {
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// groupMembers runs a query selecting (owner ID, target ID) pairs and groups
+// the target IDs by owner.
+func groupMembers(query string) (map[int64][]int, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := map[int64][]int{}
+	for rows.Next() {
+		var ownerID int64
+		var targetID int
+		if err := rows.Scan(&ownerID, &targetID); err != nil {
+			return nil, err
+		}
+		members[ownerID] = append(members[ownerID], targetID)
+	}
+	return members, rows.Err()
+}
+
+// SaveSLO inserts s when its ID is zero and updates it otherwise, returning
+// the ID.
+func SaveSLO(s SLO) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id := s.ID
+	if id == 0 {
+		res, err := tx.Exec(`INSERT INTO slos(name, objective, latency_threshold_ms, window_days) VALUES(?, ?, ?, ?)`,
+			s.Name, s.Objective, s.LatencyThresholdMs, s.WindowDays)
+		if err != nil {
+			return 0, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	} else {
+		res, err := tx.Exec(`UPDATE slos SET name = ?, objective = ?, latency_threshold_ms = ?, window_days = ? WHERE id = ?`,
+			s.Name, s.Objective, s.LatencyThresholdMs, s.WindowDays, id)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return 0, err
+		} else if n == 0 {
+			return 0, ErrNotFound
+		}
+		if _, err := tx.Exec(`DELETE FROM slo_targets WHERE slo_id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+	for _, targetID := range s.TargetIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO slo_targets(slo_id, target_id) VALUES(?, ?)`, id, targetID); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+func DeleteSLO(id int64) error {
+	res, err := db.Exec(`DELETE FROM slos WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	_, err = db.Exec(`DELETE FROM slo_targets WHERE slo_id = ?`, id)
+	return err
+}
+
+// SetSLOAlerting records whether a burn-rate alert is firing for an SLO.
+func SetSLOAlerting(id int64, alerting bool) error {
+	_, err := db.Exec(`UPDATE slos SET alerting = ? WHERE id = ?`, boolToInt(alerting), id)
+	return err
+}
+
+// CountGoodChecks counts all and good checks of the given targets (all
+// targets when empty) in (from, to], so a window ending now includes the
+// check just made. A check is good when it succeeded and, if thresholdMs > 0,
+// took at most thresholdMs.
+func CountGoodChecks(targetIDs []int, thresholdMs int, from, to time.Time) (total, good int, err error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(CASE WHEN status = 1 AND (? = 0 OR duration <= ?) THEN 1 ELSE 0 END), 0)
+        FROM checks WHERE checked_at > ? AND checked_at <= ?`
+	args := []any{thresholdMs, thresholdMs, from.UTC(), to.UTC()}
+	if len(targetIDs) > 0 {
+		query += " AND target_id IN (?" + strings.Repeat(", ?", len(targetIDs)-1) + ")"
+		for _, id := range targetIDs {
+			args = append(args, id)
+		}
+	}
+	err = db.QueryRow(query, args...).Scan(&total, &good)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	return total, good, err
+}
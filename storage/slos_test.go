@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"uptime/probes"
+)
+
+func TestCountGoodChecks(t *testing.T) {
+	if err := OpenMemory(); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	api, err := AddTarget("api", "https://api.example", "http", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, err := AddTarget("idle", "https://idle.example", "http", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One check a minute from 12:00 to 12:04: fast, slow, failed, fast, slow.
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, ms := range []int{100, 800, 100, 100, 800} {
+		res := probes.Result{Target: "https://api.example", Type: "http", Status: i != 2,
+			Duration: time.Duration(ms) * time.Millisecond, CheckedAt: t0.Add(time.Duration(i) * time.Minute)}
+		if err := SaveCheck(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		targetIDs   []int
+		thresholdMs int
+		from, to    time.Time
+		total, good int
+	}{
+		{"all", nil, 0, t0.Add(-time.Minute), t0.Add(4 * time.Minute), 5, 4},
+		{"latency threshold", []int{api}, 500, t0.Add(-time.Minute), t0.Add(4 * time.Minute), 5, 2},
+		{"threshold is inclusive", []int{api}, 800, t0.Add(-time.Minute), t0.Add(4 * time.Minute), 5, 4},
+		// The window excludes its start and includes its end.
+		{"window edges", []int{api}, 0, t0.Add(time.Minute), t0.Add(3 * time.Minute), 2, 1},
+		{"empty window", []int{api}, 500, t0.Add(10 * time.Minute), t0.Add(20 * time.Minute), 0, 0},
+		{"target without checks", []int{idle}, 500, t0.Add(-time.Minute), t0.Add(4 * time.Minute), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, good, err := CountGoodChecks(tt.targetIDs, tt.thresholdMs, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.total || good != tt.good {
+				t.Fatalf("got %d checks, %d good; want %d, %d good", total, good, tt.total, tt.good)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TargetState is the monitor's current view of a target, persisted so it
+// survives restarts.
+type TargetState struct {
+	Up                   bool       `json:"up"`
+	Since                time.Time  `json:"since"`
+	ConsecutiveFailures  int        `json:"consecutiveFailures"`
+	ConsecutiveSuccesses int        `json:"consecutiveSuccesses"`
+	LastCheckedAt        time.Time  `json:"lastCheckedAt"`
+	LastMessage          string     `json:"lastMessage"`
+	LastNotifiedAt       *time.Time `json:"lastNotifiedAt,omitempty"`
+}
+
+func createStateSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS target_state (
+                target_id INTEGER PRIMARY KEY,
+                up INTEGER,
+                since DATETIME,
+                consecutive_failures INTEGER,
+                consecutive_successes INTEGER,
+                last_checked_at DATETIME,
+                last_message TEXT,
+                last_notified_at DATETIME
+        );
+        `)
+	return err
+}
+
+const upsertStateSQL = `INSERT INTO target_state(target_id, up, since, consecutive_failures, consecutive_successes, last_checked_at, last_message, last_notified_at)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(target_id) DO UPDATE SET up = excluded.up, since = excluded.since,
+                consecutive_failures = excluded.consecutive_failures, consecutive_successes = excluded.consecutive_successes,
+                last_checked_at = excluded.last_checked_at, last_message = excluded.last_message, last_notified_at = excluded.last_notified_at`
+
+func stateArgs(targetID int, s *TargetState) []any {
+	var notified any
+	if s.LastNotifiedAt != nil {
+		notified = s.LastNotifiedAt.UTC()
+	}
+	return []any{targetID, boolToInt(s.Up), s.Since.UTC(), s.ConsecutiveFailures, s.ConsecutiveSuccesses, s.LastCheckedAt.UTC(), s.LastMessage, notified}
+}
+
+// GetTargetStates returns the persisted state of every target by ID.
+func GetTargetStates() (map[int]TargetState, error) {
+	rows, err := db.Query(`SELECT target_id, up, since, consecutive_failures, consecutive_successes, last_checked_at, last_message, last_notified_at FROM target_state`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	states := map[int]TargetState{}
+	for rows.Next() {
+		var id int
+		s, err := scanState(rows, &id)
+		if err != nil {
+			return nil, err
+		}
+		states[id] = *s
+	}
+	return states, rows.Err()
+}
+
+func scanState(row rowScanner, targetID *int) (*TargetState, error) {
+	var s TargetState
+	var up int
+	var message sql.NullString
+	var notified sql.NullTime
+	if err := row.Scan(targetID, &up, &s.Since, &s.ConsecutiveFailures, &s.ConsecutiveSuccesses, &s.LastCheckedAt, &message, &notified); err != nil {
+		return nil, err
+	}
+	s.Up = up == 1
+	s.LastMessage = message.String
+	if notified.Valid {
+		s.LastNotifiedAt = &notified.Time
+	}
+	return &s, nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Token scopes. A read token may only make GET, HEAD and OPTIONS requests.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// tokenPrefix marks API tokens so they are easy to recognize in logs and
+// secret scanners.
+const tokenPrefix = "upt_"
+
+// APIToken is a bearer token for automation. Like sessions, only the
+// SHA-256 of the token is stored; the token itself is shown once on
+// creation.
+type APIToken struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+	// Role is the role of the owner, which the token acts with.
+	Role       string     `json:"role"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+func createTokenSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS api_tokens (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                user_id INTEGER,
+                name TEXT,
+                token_hash TEXT UNIQUE,
+                scope TEXT,
+                created_at DATETIME,
+                expires_at DATETIME,
+                last_used_at DATETIME
+        );
+        `)
+	return err
+}
+
+const tokenColumns = `t.id, t.user_id, u.username, u.role, t.name, t.scope, t.created_at, t.expires_at, t.last_used_at`
+
+func scanToken(s rowScanner) (*APIToken, error) {
+	var t APIToken
+	var expires, lastUsed sql.NullTime
+	if err := s.Scan(&t.ID, &t.UserID, &t.Username, &t.Role, &t.Name, &t.Scope, &t.CreatedAt, &expires, &lastUsed); err != nil {
+		return nil, err
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return &t, nil
+}
+
+// CreateToken issues a token for userID and returns it together with the
+// secret, which cannot be retrieved again. A nil expiresAt never expires.
+func CreateToken(userID int64, name, scope string, createdAt time.Time, expiresAt *time.Time) (*APIToken, string, error) {
+	secret, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret = tokenPrefix + secret
+	var expires any
+	if expiresAt != nil {
+		expires = expiresAt.UTC()
+	}
+	res, err := db.Exec(`INSERT INTO api_tokens(user_id, name, token_hash, scope, created_at, expires_at) VALUES(?, ?, ?, ?, ?, ?)`,
+		userID, name, hashToken(secret), scope, createdAt.UTC(), expires)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	t, err := scanToken(db.QueryRow(`SELECT `+tokenColumns+` FROM api_tokens t JOIN users u ON u.id = t.user_id WHERE t.id = ?`, id))
+	return t, secret, err
+}
+
+// ListTokens returns the tokens of userID, or of every user when userID is 0.
+func ListTokens(userID int64) ([]APIToken, error) {
+	rows, err := db.Query(`SELECT `+tokenColumns+` FROM api_tokens t JOIN users u ON u.id = t.user_id
+        WHERE ? = 0 OR t.user_id = ? ORDER BY t.id`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []APIToken{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// LookupToken returns the token matching secret, or ErrNotFound if there is
+// none or it expired before now. Its last use is recorded with minute
+// granularity.
+func LookupToken(secret string, now time.Time) (*APIToken, error) {
+	t, err := scanToken(db.QueryRow(`SELECT `+tokenColumns+` FROM api_tokens t JOIN users u ON u.id = t.user_id
+        WHERE t.token_hash = ?`, hashToken(secret)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= time.Minute {
+		if _, err := db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now.UTC(), t.ID); err != nil {
+			return nil, err
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
+
+// RevokeToken deletes a token. If userID is not 0 the token must belong to
+// that user.
+func RevokeToken(id, userID int64) error {
+	res, err := db.Exec(`DELETE FROM api_tokens WHERE id = ? AND (? = 0 OR user_id = ?)`, id, userID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
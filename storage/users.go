@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned by Authenticate for an unknown user or a
+// wrong password.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Roles, from least to most privileged: viewers can read everything,
+// operators can also act on incidents and monitoring, and admins can manage
+// targets, settings, notifications and users.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// IsRole reports whether role is a known role.
+func IsRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// RoleAtLeast reports whether role grants everything min does.
+func RoleAtLeast(role, min string) bool {
+	return IsRole(role) && roleRank[role] >= roleRank[min]
+}
+
+// ErrLastAdmin is returned when a change would leave no admin.
+var ErrLastAdmin = errors.New("cannot remove the last admin")
+
+// User is a local account. Passwords are only stored as bcrypt hashes.
+type User struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Session is a browser login. The session token itself is never stored, only
+// its SHA-256, so a copy of the database cannot be used to log in.
+type Session struct {
+	User
+	CSRFToken string
+	ExpiresAt time.Time
+}
+
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return hash
+})
+
+func createUserSchema() error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS users (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                username TEXT UNIQUE,
+                password_hash TEXT,
+                created_at DATETIME
+        );
+        CREATE TABLE IF NOT EXISTS sessions (
+                token_hash TEXT PRIMARY KEY,
+                user_id INTEGER,
+                csrf_token TEXT,
+                expires_at DATETIME
+        );
+        CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+        `)
+	if err != nil {
+		return err
+	}
+	// Users created before roles existed were admins.
+	added, err := addColumn("users", "role TEXT DEFAULT 'viewer'")
+	if err != nil {
+		return err
+	}
+	if added {
+		if _, err := db.Exec("UPDATE users SET role = ?", RoleAdmin); err != nil {
+			return err
+		}
+	}
+	if _, err := addColumn("users", "oidc_subject TEXT"); err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_subject ON users(oidc_subject) WHERE oidc_subject IS NOT NULL")
+	return err
+}
+
+// newToken returns a random URL-safe token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func CountUsers() (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+	return n, err
+}
+
+// CreateUser adds a local user with a bcrypt-hashed password.
+func CreateUser(username, password, role string, at time.Time) (*User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if !IsRole(role) {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.Exec("INSERT INTO users(username, password_hash, role, created_at) VALUES(?, ?, ?, ?)", username, string(hash), role, at.UTC())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("user %q already exists", username)
+		}
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &User{ID: id, Username: username, Role: role, CreatedAt: at}, nil
+}
+
+// SyncOIDCUser returns the user linked to an identity provider subject,
+// creating it on first login, and sets its role. A new user gets username
+// and no local password; if a local user already has that name the login is
+// refused rather than linked, so an IdP account cannot take over a local
+// one. The last admin keeps its role even if the provider says otherwise.
+func SyncOIDCUser(subject, username, role string, at time.Time) (*User, error) {
+	if !IsRole(role) {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+	var id int64
+	err := db.QueryRow("SELECT id FROM users WHERE oidc_subject = ?", subject).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		res, err := db.Exec("INSERT INTO users(username, password_hash, role, created_at, oidc_subject) VALUES(?, '', ?, ?, ?)",
+			username, role, at.UTC(), subject)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return nil, fmt.Errorf("user %q already exists", username)
+			}
+			return nil, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if err := SetRole(id, role); err != nil && !errors.Is(err, ErrLastAdmin) {
+		return nil, err
+	}
+	return GetUser(id)
+}
+
+func ListUsers() ([]User, error) {
+	rows, err := db.Query("SELECT id, username, role, created_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func GetUser(id int64) (*User, error) {
+	var u User
+	err := db.QueryRow("SELECT id, username, role, created_at FROM users WHERE id = ?", id).
+		Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SetRole changes a user's role. Demoting the last admin fails with
+// ErrLastAdmin.
+func SetRole(id int64, role string) error {
+	if !IsRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := checkLastAdmin(tx, id, role); err != nil {
+		return err
+	}
+	res, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", role, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// DeleteUser removes a user with their sessions and API tokens. Deleting the
+// last admin fails with ErrLastAdmin.
+func DeleteUser(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := checkLastAdmin(tx, id, ""); err != nil {
+		return err
+	}
+	res, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	for _, q := range []string{"DELETE FROM sessions WHERE user_id = ?", "DELETE FROM api_tokens WHERE user_id = ?"} {
+		if _, err := tx.Exec(q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkLastAdmin returns ErrLastAdmin if giving user id the new role (or
+// deleting it, for "") would leave no admin.
+func checkLastAdmin(tx *sql.Tx, id int64, role string) error {
+	if role == RoleAdmin {
+		return nil
+	}
+	var others int
+	err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ? AND id != ?", RoleAdmin, id).Scan(&others)
+	if err != nil {
+		return err
+	}
+	var current string
+	err = tx.QueryRow("SELECT role FROM users WHERE id = ?", id).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if current == RoleAdmin && others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// Authenticate checks a username and password.
+func Authenticate(username, password string) (*User, error) {
+	var u User
+	var hash string
+	err := db.QueryRow("SELECT id, username, role, password_hash, created_at FROM users WHERE username = ?", username).
+		Scan(&u.ID, &u.Username, &u.Role, &hash, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Compare anyway so unknown users take as long as wrong passwords.
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &u, nil
+}
+
+// SetPassword replaces a user's password and ends all of their sessions.
+func SetPassword(userID int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	res, err := db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(hash), userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	_, err = db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// CreateSession starts a session for userID and returns its token and CSRF
+// token.
+func CreateSession(userID int64, expiresAt time.Time) (token, csrf string, err error) {
+	if token, err = newToken(); err != nil {
+		return "", "", err
+	}
+	if csrf, err = newToken(); err != nil {
+		return "", "", err
+	}
+	_, err = db.Exec("INSERT INTO sessions(token_hash, user_id, csrf_token, expires_at) VALUES(?, ?, ?, ?)",
+		hashToken(token), userID, csrf, expiresAt.UTC())
+	return token, csrf, err
+}
+
+// GetSession returns the session for token, or ErrNotFound if there is none
+// or it expired before now.
+func GetSession(token string, now time.Time) (*Session, error) {
+	var s Session
+	err := db.QueryRow(`SELECT u.id, u.username, u.role, u.created_at, s.csrf_token, s.expires_at
+        FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.token_hash = ?`, hashToken(token)).
+		Scan(&s.ID, &s.Username, &s.Role, &s.CreatedAt, &s.CSRFToken, &s.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !now.Before(s.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func DeleteSession(token string) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashToken(token))
+	return err
+}
+
+// DeleteExpiredSessions removes sessions that expired before now.
+func DeleteExpiredSessions(now time.Time) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now.UTC())
+	return err
+}
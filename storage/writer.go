@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"uptime/probes"
+)
+
+const (
+	// writeBatchSize is the most checks committed in one transaction.
+	writeBatchSize = 500
+	// writeFlushInterval bounds how long a check waits in the buffer.
+	writeFlushInterval = time.Second
+	// writeBufferSize is how many checks may be queued before SaveCheck
+	// blocks.
+	writeBufferSize = 4 * writeBatchSize
+)
+
+var errWriterClosed = errors.New("storage: check writer is closed")
+
+// checkRow is a queued check result and the target it belongs to. A zero
+// TargetID is resolved from the result's URL when the row is inserted.
+// State, if set, is upserted into target_state alongside the check.
+type checkRow struct {
+	TargetID int
+	probes.Result
+	State *TargetState
+}
+
+// checkWriter funnels SaveCheck calls through a single goroutine that commits
+// them in batches, so thousands of targets cost a handful of transactions
+// per loop instead of one autocommit INSERT each.
+type checkWriter struct {
+	mu       sync.RWMutex
+	closed   bool
+	closeErr error
+	in       chan checkRow
+	flush    chan chan error
+	done     chan struct{}
+	db       *sql.DB
+
+	statusMu sync.Mutex
+	status   WriteStatus
+}
+
+// WriteStatus reports the batches the check writer failed to commit since
+// the database was opened. SaveCheck has already returned by the time a
+// batch fails, so this is where such failures show.
+type WriteStatus struct {
+	DroppedChecks int64      `json:"droppedChecks"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// WriterStatus returns the check writer's failures so far.
+func WriterStatus() WriteStatus {
+	w := writer
+	if w == nil {
+		return WriteStatus{}
+	}
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+	return w.status
+}
+
+var writer *checkWriter
+
+func startWriter(conn *sql.DB) {
+	writer = &checkWriter{
+		in:    make(chan checkRow, writeBufferSize),
+		flush: make(chan chan error),
+		done:  make(chan struct{}),
+		db:    conn,
+	}
+	go writer.run()
+}
+
+// stopWriter commits the queued checks and returns the error of that last
+// batch, if any.
+func stopWriter() error {
+	if writer == nil {
+		return nil
+	}
+	err := writer.close()
+	writer = nil
+	return err
+}
+
+func (w *checkWriter) enqueue(res checkRow) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return errWriterClosed
+	}
+	w.in <- res
+	return nil
+}
+
+// sync blocks until every check enqueued before the call is committed.
+func (w *checkWriter) sync() error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return nil
+	}
+	ack := make(chan error, 1)
+	w.flush <- ack
+	w.mu.RUnlock()
+	return <-ack
+}
+
+func (w *checkWriter) close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.in)
+	w.mu.Unlock()
+	<-w.done
+	return w.closeErr
+}
+
+func (w *checkWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(writeFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]checkRow, 0, writeBatchSize)
+	commit := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := w.insert(batch)
+		if err != nil {
+			log.Printf("storage: dropping %d checks: %v", len(batch), err)
+			now := time.Now()
+			w.statusMu.Lock()
+			w.status.DroppedChecks += int64(len(batch))
+			w.status.LastError, w.status.LastErrorAt = err.Error(), &now
+			w.statusMu.Unlock()
+		}
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		select {
+		case res, ok := <-w.in:
+			if !ok {
+				w.closeErr = commit()
+				return
+			}
+			batch = append(batch, res)
+			if len(batch) >= writeBatchSize {
+				commit()
+			}
+		case <-ticker.C:
+			commit()
+		case ack := <-w.flush:
+			var err error
+		drain:
+			for {
+				select {
+				case res, ok := <-w.in:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, res)
+					if len(batch) >= writeBatchSize {
+						if cerr := commit(); cerr != nil {
+							err = cerr
+						}
+					}
+				default:
+					break drain
+				}
+			}
+			if cerr := commit(); cerr != nil {
+				err = cerr
+			}
+			ack <- err
+		}
+	}
+}
+
+func (w *checkWriter) insert(batch []checkRow) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO checks (target_id, target, type, status, duration, checked_at, message)
+        VALUES (COALESCE(NULLIF(?, 0), (SELECT MIN(id) FROM targets WHERE url = ?)), ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	var stateStmt *sql.Stmt
+	for _, res := range batch {
+		if _, err := stmt.Exec(res.TargetID, res.Target, res.Target, res.Type, boolToInt(res.Status), res.Duration.Milliseconds(), res.CheckedAt.UTC(), res.Message); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if res.State == nil {
+			continue
+		}
+		if stateStmt == nil {
+			if stateStmt, err = tx.Prepare(upsertStateSQL); err != nil {
+				tx.Rollback()
+				return err
+			}
+			defer stateStmt.Close()
+		}
+		if _, err := stateStmt.Exec(stateArgs(res.TargetID, res.State)...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"uptime/probes"
+)
+
+func TestWriterReportsDroppedChecks(t *testing.T) {
+	if err := OpenMemory(); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if _, err := db.Exec("DROP TABLE checks"); err != nil {
+		t.Fatal(err)
+	}
+
+	res := probes.Result{Target: "https://example.com", Type: "http", CheckedAt: time.Now()}
+	if err := SaveCheck(res); err != nil {
+		t.Fatalf("SaveCheck: %v, want the row to be queued", err)
+	}
+	if err := Flush(); err == nil {
+		t.Fatal("Flush succeeded without a checks table")
+	}
+	st := WriterStatus()
+	if st.DroppedChecks != 1 || st.LastError == "" || st.LastErrorAt == nil {
+		t.Fatalf("WriterStatus() = %+v, want one dropped check with its error", st)
+	}
+
+	// Close reports the checks it could not commit on the way out.
+	if err := SaveCheck(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(); err == nil {
+		t.Fatal("Close succeeded with an uncommittable check queued")
+	}
+}
+
+// BenchmarkSaveCheck measures check write throughput through the batched
+// writer on a file database in WAL mode, alone and while API-style reads of
+// recent checks run against the same database.
+func BenchmarkSaveCheck(b *testing.B) {
+	for _, readers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("readers=%d", readers), func(b *testing.B) {
+			if err := Open(filepath.Join(b.TempDir(), "bench.db")); err != nil {
+				b.Fatal(err)
+			}
+			defer Close()
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						if _, err := LastChecks(1); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				}()
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res := probes.Result{
+					Target:    fmt.Sprintf("https://target-%d.example", i%5000),
+					Type:      "http",
+					Status:    i%50 != 0,
+					Duration:  time.Duration(i%300) * time.Millisecond,
+					CheckedAt: time.Now(),
+					Message:   "200 OK",
+				}
+				if err := SaveCheck(res); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := Flush(); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "checks/s")
+		})
+	}
+}